@@ -0,0 +1,255 @@
+package collider
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/downflux/go-bvh/id"
+	"github.com/downflux/go-database/agent"
+	"github.com/downflux/go-database/feature"
+	"github.com/downflux/go-database/flags"
+)
+
+// Altitude is the bitmask of height layers which a body occupies. Two bodies
+// collide only if they occupy at least one common layer. A body may occupy
+// several layers, e.g. a tall building may block both ground and air units.
+type Altitude uint64
+
+const (
+	AltitudeGround Altitude = 1 << iota
+	AltitudeAir
+)
+
+// terrain returns the default altitude of a body with the input flags, i.e.
+// AltitudeAir if the body is in the air, and AltitudeGround otherwise. This
+// matches the terrain layer check of go-database.
+func terrain(f flags.F) Altitude {
+	if f&flags.FTerrainAir == flags.FTerrainAir {
+		return AltitudeAir
+	}
+	return AltitudeGround
+}
+
+// altitudes tracks the altitudes of agents across ticks.
+//
+// Agents occupy the default altitude of their terrain flags unless the
+// altitude was set explicitly. Altitude transitions are requested between
+// ticks, and take effect at the start of the first tick in which the agent
+// does not overlap any body in its new altitude, e.g. a helicopter hovers until
+// its landing spot is clear. Transitions are staged during the tick, and are
+// only committed once the tick has been committed, which leaves the altitudes
+// untouched by an abandoned tick.
+type altitudes struct {
+	// of maps each agent to its explicit altitude. of is only written to
+	// serially, and is read concurrently by the workers.
+	of map[id.ID]Altitude
+
+	// pending maps each agent to its requested altitude.
+	pending map[id.ID]Altitude
+
+	// feature is the user-specified feature altitude hook.
+	feature func(f feature.RO) Altitude
+
+	// index is used to remove agents which have been deleted from the
+	// store.
+	index map[id.ID]struct{}
+
+	// staged maps each agent whose transition is clear in the current tick
+	// to its new altitude. Staged transitions are in effect during the
+	// tick, but are only committed once the tick has been committed.
+	staged map[id.ID]Altitude
+
+	// stale indicates the tracked agents include agents which have been
+	// deleted from the store.
+	stale bool
+
+	// queue is the per-tick buffer of agents with a pending transition,
+	// sorted by ID. Once the transitions have been staged, queue holds
+	// only the agents with a staged transition.
+	queue byID
+}
+
+func newAltitudes(feature func(f feature.RO) Altitude) *altitudes {
+	return &altitudes{
+		of:      map[id.ID]Altitude{},
+		pending: map[id.ID]Altitude{},
+		staged:  map[id.ID]Altitude{},
+		index:   map[id.ID]struct{}{},
+		feature: feature,
+	}
+}
+
+// agent returns the current altitude of the input agent.
+func (h *altitudes) agent(a agent.RO) Altitude {
+	if h == nil || len(h.of) == 0 && len(h.staged) == 0 {
+		return terrain(a.Flags())
+	}
+	if k, ok := h.staged[a.ID()]; ok {
+		return k
+	}
+	if k, ok := h.of[a.ID()]; ok {
+		return k
+	}
+	return terrain(a.Flags())
+}
+
+// featureAltitude returns the altitude of the input feature.
+func (h *altitudes) featureAltitude(f feature.RO) Altitude {
+	if h == nil || h.feature == nil {
+		return terrain(f.Flags())
+	}
+	return h.feature(f)
+}
+
+func (h *altitudes) set(x id.ID, k Altitude) {
+	if k == 0 {
+		panic(fmt.Sprintf("cannot set agent %v to an empty altitude", x))
+	}
+	h.pending[x] = k
+}
+
+// SetAltitude requests the agent with the input ID to move to the input
+// altitude, e.g. for a helicopter to land. The transition takes effect at the
+// start of the first tick in which the agent does not overlap any agent or
+// feature in the new altitude which it would collide with, and is reported via
+// the OnAltitude hook once the tick has been committed. A transition in a tick
+// which is abandoned is retried in the next tick. A later request replaces a
+// pending request. SetAltitude must not be called during a tick.
+func (c *C) SetAltitude(x id.ID, k Altitude) { c.altitudes.set(x, k) }
+
+// stage finds all pending altitude transitions which are clear, and stages the
+// transitions for the current tick. stage is called serially at the start of a
+// tick, after the broadphase has been updated, and uses the buffers of the
+// input worker. stage does not modify the committed altitudes; see commit.
+func (c *C) stage(w *worker) {
+	h := c.altitudes
+	if len(h.pending) == 0 && len(h.of) == 0 {
+		return
+	}
+
+	// m is the number of tracked agents which are still in the store.
+	m := 0
+	h.queue = h.queue[:0]
+	for _, a := range c.agents {
+		if _, ok := h.of[a.ID()]; ok {
+			m++
+		}
+		if _, ok := h.pending[a.ID()]; ok {
+			m++
+			h.queue = append(h.queue, a)
+		}
+	}
+
+	// Agents which have been deleted from the store are removed once the
+	// tick has been committed.
+	h.stale = m < len(h.of)+len(h.pending)
+
+	// Transitions are staged in ID order, as a staged transition may block
+	// a later transition.
+	sort.Sort(&h.queue)
+	n := 0
+	for _, a := range h.queue {
+		k := h.pending[a.ID()]
+		if !c.isClear(w, a, k) {
+			continue
+		}
+		h.staged[a.ID()] = k
+		h.queue[n] = a
+		n++
+	}
+	for i := n; i < len(h.queue); i++ {
+		h.queue[i] = nil
+	}
+	h.queue = h.queue[:n]
+	w.a = nil
+}
+
+// commit applies the transitions staged in the current tick, and reports each
+// transition to the input hook, if set. commit is called serially once the
+// tick has been committed.
+func (h *altitudes) commit(onAltitude func(a agent.RO, k Altitude), agents []agent.RO) {
+	if h.stale {
+		h.prune(agents)
+		h.stale = false
+	}
+	for _, a := range h.queue {
+		k := h.staged[a.ID()]
+		delete(h.pending, a.ID())
+
+		// Agents in their default altitude are not tracked, which
+		// keeps the common case of the altitude lookup fast.
+		if k == terrain(a.Flags()) {
+			delete(h.of, a.ID())
+		} else {
+			h.of[a.ID()] = k
+		}
+		if onAltitude != nil {
+			onAltitude(a, k)
+		}
+	}
+	h.discard()
+}
+
+// discard drops the transitions staged in the current tick, e.g. if the tick
+// was abandoned. The transitions remain pending.
+func (h *altitudes) discard() {
+	for x := range h.staged {
+		delete(h.staged, x)
+	}
+	for i := range h.queue {
+		h.queue[i] = nil
+	}
+	h.queue = h.queue[:0]
+}
+
+// prune removes the altitudes of agents which are not in the input list of
+// agents.
+func (h *altitudes) prune(agents []agent.RO) {
+	for x := range h.index {
+		delete(h.index, x)
+	}
+	for _, a := range agents {
+		h.index[a.ID()] = struct{}{}
+	}
+	for x := range h.of {
+		if _, ok := h.index[x]; !ok {
+			delete(h.of, x)
+		}
+	}
+	for x := range h.pending {
+		if _, ok := h.index[x]; !ok {
+			delete(h.pending, x)
+		}
+	}
+}
+
+// isClear checks if the input agent does not overlap any agent or feature in
+// the input altitude which it would collide with. Bodies which are only
+// touching the agent do not block the transition.
+func (c *C) isClear(w *worker, a agent.RO, k Altitude) bool {
+	w.set(a)
+	w.altitude = k
+
+	p, r := a.Position(), a.Radius()
+	c.queryAgents(w, w.filterAgent)
+	for _, b := range w.ns {
+		q := b.Position()
+		dx, dy := p.X()-q.X(), p.Y()-q.Y()
+		if s := r + b.Radius(); dx*dx+dy*dy < s*s {
+			return false
+		}
+	}
+	c.queryFeatures(w, w.filterFeature)
+	for _, f := range w.fs {
+		// (x, y) is the point of the feature which is closest to the
+		// center of the agent.
+		g := f.AABB()
+		x := math.Max(g.Min().X(), math.Min(p.X(), g.Max().X()))
+		y := math.Max(g.Min().Y(), math.Min(p.Y(), g.Max().Y()))
+		if dx, dy := p.X()-x, p.Y()-y; dx*dx+dy*dy < r*r {
+			return false
+		}
+	}
+	return true
+}
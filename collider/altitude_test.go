@@ -0,0 +1,159 @@
+package collider
+
+import (
+	"testing"
+	"time"
+
+	"github.com/downflux/go-bvh/id"
+	"github.com/downflux/go-database/agent"
+	"github.com/downflux/go-database/database"
+	"github.com/downflux/go-database/feature"
+	"github.com/downflux/go-geometry/2d/hyperrectangle"
+	"github.com/downflux/go-geometry/2d/vector"
+)
+
+func TestAltitude(t *testing.T) {
+	type config struct {
+		name     string
+		feature  func(f feature.RO) Altitude
+		altitude Altitude
+
+		// want indicates the agent is expected to pass over the
+		// feature.
+		want bool
+	}
+
+	tall := func(f feature.RO) Altitude { return AltitudeGround | AltitudeAir }
+
+	configs := []config{
+		{name: "Ground", altitude: AltitudeGround, want: false},
+		{name: "Air", altitude: AltitudeAir, want: true},
+		{name: "Air/Tall", feature: tall, altitude: AltitudeAir, want: false},
+		{name: "Both", altitude: AltitudeGround | AltitudeAir, want: false},
+	}
+
+	for _, c := range configs {
+		t.Run(c.name, func(t *testing.T) {
+			db := database.New(database.DefaultO)
+			collider := New(db, O{
+				PoolSize:        DefaultO.PoolSize,
+				FeatureAltitude: c.feature,
+			})
+			defer collider.Close()
+
+			o := idle(vector.V{0, 0})
+			o.TargetVelocity = vector.V{1, 0}
+			x := db.InsertAgent(o).ID()
+			db.InsertFeature(feature.O{AABB: *hyperrectangle.New(vector.V{1, -1}, vector.V{2, 1})})
+
+			collider.SetAltitude(x, c.altitude)
+			for i := 0; i < 300; i++ {
+				collider.Tick(20 * time.Millisecond)
+			}
+
+			if got := db.GetAgentOrDie(x).Position().X() > 2+R; got != c.want {
+				t.Errorf("passed = %v, want = %v", got, c.want)
+			}
+		})
+	}
+}
+
+func TestAltitudeTransition(t *testing.T) {
+	type step struct {
+		// f is an optional mutation before the tick.
+		f func(db *database.DB, collider *C, a id.ID, b id.ID)
+
+		// want is the altitude transitions applied in the tick.
+		want []Altitude
+	}
+
+	type config struct {
+		name  string
+		steps []step
+	}
+
+	land := func(db *database.DB, collider *C, a id.ID, b id.ID) {
+		collider.SetAltitude(a, AltitudeGround)
+	}
+
+	configs := []config{
+		{
+			name: "Takeoff",
+			steps: []step{
+				{
+					f: func(db *database.DB, collider *C, a id.ID, b id.ID) {
+						collider.SetAltitude(a, AltitudeAir)
+					},
+					want: []Altitude{AltitudeAir},
+				},
+				{want: nil},
+			},
+		},
+		{
+			// The landing spot is occupied by b until b is deleted.
+			name: "Landing",
+			steps: []step{
+				{
+					f: func(db *database.DB, collider *C, a id.ID, b id.ID) {
+						collider.SetAltitude(a, AltitudeAir)
+					},
+					want: []Altitude{AltitudeAir},
+				},
+				{f: land, want: nil},
+				{want: nil},
+				{
+					f:    func(db *database.DB, collider *C, a id.ID, b id.ID) { db.DeleteAgent(b) },
+					want: []Altitude{AltitudeGround},
+				},
+			},
+		},
+		{
+			// A landing which is only touching another agent is
+			// clear.
+			name: "Landing/Touching",
+			steps: []step{
+				{
+					f: func(db *database.DB, collider *C, a id.ID, b id.ID) {
+						collider.SetAltitude(a, AltitudeAir)
+						db.SetAgentPosition(b, vector.V{2 * R, 0})
+					},
+					want: []Altitude{AltitudeAir},
+				},
+				{f: land, want: []Altitude{AltitudeGround}},
+			},
+		},
+	}
+
+	for _, c := range configs {
+		t.Run(c.name, func(t *testing.T) {
+			var got []Altitude
+
+			db := database.New(database.DefaultO)
+			collider := New(db, O{
+				PoolSize:   DefaultO.PoolSize,
+				OnAltitude: func(a agent.RO, k Altitude) { got = append(got, k) },
+			})
+			defer collider.Close()
+
+			a := db.InsertAgent(idle(vector.V{0, 0})).ID()
+			b := db.InsertAgent(idle(vector.V{R / 2, 0})).ID()
+
+			for i, s := range c.steps {
+				got = nil
+				if s.f != nil {
+					s.f(db, collider, a, b)
+				}
+				collider.Tick(20 * time.Millisecond)
+
+				if len(got) != len(s.want) {
+					t.Fatalf("[%v]: OnAltitude() = %v, want = %v", i, got, s.want)
+				}
+				for j := range got {
+					if got[j] != s.want[j] {
+						t.Errorf("[%v]: OnAltitude() = %v, want = %v", i, got, s.want)
+					}
+				}
+			}
+		})
+	}
+}
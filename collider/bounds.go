@@ -0,0 +1,92 @@
+package collider
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/downflux/go-database/projectile"
+	"github.com/downflux/go-geometry/2d/hyperrectangle"
+	"github.com/downflux/go-geometry/2d/vector"
+)
+
+// Despawner is an optional extension of a store which deletes projectiles,
+// and is used to despawn projectiles which leave the bounds of the world. See
+// O.Despawn for more information.
+type Despawner interface {
+	// DeleteProjectiles deletes the input projectiles from the store, and
+	// is called serially once the tick has been committed.
+	DeleteProjectiles(ps []projectile.RO)
+}
+
+// bounds is the rectangle which confines the agents of the world.
+//
+// Agents are confined exactly by the edges of the rectangle, i.e. the velocity
+// of an agent is clamped per axis so that the agent stops when its bounding
+// circle touches an edge, and slides along the edge otherwise. Unlike border
+// features, the edges do not meet at corner seams.
+type bounds struct {
+	x0, y0 float64
+	x1, y1 float64
+
+	// exits is the per-tick buffer of projectiles which are outside the
+	// bounds.
+	exits exits
+}
+
+func newBounds(r hyperrectangle.R) *bounds {
+	min, max := r.Min(), r.Max()
+	if !(min.X() < max.X() && min.Y() < max.Y()) {
+		panic(fmt.Sprintf("bounds specified %v are empty", r))
+	}
+	return &bounds{
+		x0: min.X(),
+		y0: min.Y(),
+		x1: max.X(),
+		y1: max.Y(),
+	}
+}
+
+// clamp limits the pipeline velocity of the current agent so that the agent
+// does not leave the bounds during the tick. An agent which is already outside
+// the bounds is not allowed to move further out, but is not forced back in.
+func (b *bounds) clamp(s *State) {
+	if b == nil {
+		return
+	}
+	t := s.Duration.Seconds()
+	if t <= 0 {
+		return
+	}
+	p, r := s.Agent.Position(), s.Agent.Radius()
+	s.Velocity.SetX(clamp(s.Velocity.X(), (b.x0+r-p.X())/t, (b.x1-r-p.X())/t))
+	s.Velocity.SetY(clamp(s.Velocity.Y(), (b.y0+r-p.Y())/t, (b.y1-r-p.Y())/t))
+}
+
+// clamp limits the input velocity component v to the range [lo, hi], where
+// the range is first extended to include zero.
+func clamp(v float64, lo float64, hi float64) float64 {
+	return math.Max(math.Min(lo, 0), math.Min(v, math.Max(hi, 0)))
+}
+
+// contains checks if the input position is inside the bounds, including the
+// edges.
+func (b *bounds) contains(p vector.V) bool {
+	return p.X() >= b.x0 && p.X() <= b.x1 && p.Y() >= b.y0 && p.Y() <= b.y1
+}
+
+// exit records the input projectile as being outside the bounds if its next
+// position q is outside the bounds, regardless of its current position, e.g.
+// if the projectile was inserted outside the bounds. exit is called serially
+// during generation.
+func (b *bounds) exit(x projectile.RO, q vector.V) {
+	if !b.contains(q) {
+		b.exits = append(b.exits, x)
+	}
+}
+
+// exits sorts projectiles by ID.
+type exits []projectile.RO
+
+func (s *exits) Len() int           { return len(*s) }
+func (s *exits) Swap(i, j int)      { (*s)[i], (*s)[j] = (*s)[j], (*s)[i] }
+func (s *exits) Less(i, j int) bool { return (*s)[i].ID() < (*s)[j].ID() }
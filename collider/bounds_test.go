@@ -0,0 +1,163 @@
+package collider
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/downflux/go-database/agent"
+	"github.com/downflux/go-database/database"
+	"github.com/downflux/go-database/projectile"
+	"github.com/downflux/go-geometry/2d/hyperrectangle"
+	"github.com/downflux/go-geometry/2d/vector"
+	"github.com/downflux/go-geometry/2d/vector/polar"
+)
+
+func TestBounds(t *testing.T) {
+	type config struct {
+		name string
+		o    agent.O
+
+		// want is the position of the agent after the tick.
+		want vector.V
+	}
+
+	moving := func(p vector.V, v vector.V) agent.O {
+		o := idle(p)
+		o.TargetVelocity = v
+		o.Velocity = v
+		o.Heading = polar.V{1, math.Atan2(v.Y(), v.X())}
+		return o
+	}
+
+	configs := []config{
+		{
+			name: "Inside",
+			o:    moving(vector.V{5, 5}, vector.V{1, 0}),
+			want: vector.V{5.1, 5},
+		},
+		{
+			name: "Edge",
+			o:    moving(vector.V{9.3, 5}, vector.V{5, 0}),
+			want: vector.V{10 - R, 5},
+		},
+		{
+			name: "Edge/Slide",
+			o:    moving(vector.V{9.3, 5}, vector.V{5, 5}),
+			want: vector.V{10 - R, 5.5},
+		},
+		{
+			name: "Corner",
+			o:    moving(vector.V{0.7, 0.7}, vector.V{-5, -5}),
+			want: vector.V{R, R},
+		},
+		{
+			// An agent outside the bounds may move back in.
+			name: "Outside",
+			o:    moving(vector.V{11, 5}, vector.V{-5, 0}),
+			want: vector.V{10.5, 5},
+		},
+		{
+			// An agent outside the bounds may not move further out.
+			name: "Outside/Away",
+			o:    moving(vector.V{11, 5}, vector.V{5, 0}),
+			want: vector.V{11, 5},
+		},
+	}
+
+	for _, c := range configs {
+		t.Run(c.name, func(t *testing.T) {
+			db := database.New(database.DefaultO)
+			collider := New(db, O{
+				PoolSize: DefaultO.PoolSize,
+				Bounds:   hyperrectangle.New(vector.V{0, 0}, vector.V{10, 10}),
+			})
+			defer collider.Close()
+
+			a := db.InsertAgent(c.o)
+			collider.Tick(100 * time.Millisecond)
+
+			if got := db.GetAgentOrDie(a.ID()).Position(); !vector.Within(got, c.want) {
+				t.Errorf("Position() = %v, want = %v", got, c.want)
+			}
+		})
+	}
+}
+
+func TestBoundsExit(t *testing.T) {
+	type config struct {
+		name    string
+		despawn bool
+
+		// want is the number of projectiles in the database after the
+		// tick.
+		want int
+
+		// again is the number of projectiles reported in a second tick.
+		again int
+	}
+
+	configs := []config{
+		// Projectiles which remain outside the bounds are reported
+		// again.
+		{name: "Report", despawn: false, want: 3, again: 2},
+		{name: "Despawn", despawn: true, want: 1, again: 0},
+	}
+
+	for _, c := range configs {
+		t.Run(c.name, func(t *testing.T) {
+			var exits []projectile.RO
+
+			db := database.New(database.DefaultO)
+			collider := New(db, O{
+				PoolSize: DefaultO.PoolSize,
+				Bounds:   hyperrectangle.New(vector.V{0, 0}, vector.V{10, 10}),
+				OnExit: func(ps []projectile.RO) {
+					exits = append(exits, ps...)
+				},
+				Despawn: c.despawn,
+			})
+			defer collider.Close()
+
+			var xs []projectile.RO
+			for _, p := range []vector.V{{9.95, 5}, {5, 5}, {11, 5}} {
+				xs = append(xs, db.InsertProjectile(projectile.O{
+					Position:       p,
+					TargetPosition: p,
+					TargetVelocity: vector.V{1, 0},
+					Velocity:       vector.V{1, 0},
+					Heading:        polar.V{1, 0},
+					Radius:         R,
+				}))
+			}
+
+			collider.Tick(100 * time.Millisecond)
+
+			// The first projectile crosses the edge of the bounds
+			// during the tick, and the last projectile was inserted
+			// outside the bounds.
+			if got, want := len(exits), 2; got != want {
+				t.Fatalf("len(exits) = %v, want = %v", got, want)
+			}
+			for i, x := range []projectile.RO{xs[0], xs[2]} {
+				if got, want := exits[i].ID(), x.ID(); got != want {
+					t.Errorf("ID() = %v, want = %v", got, want)
+				}
+			}
+
+			got := 0
+			for range db.ListProjectiles() {
+				got++
+			}
+			if got != c.want {
+				t.Errorf("len(ListProjectiles()) = %v, want = %v", got, c.want)
+			}
+
+			exits = exits[:0]
+			collider.Tick(100 * time.Millisecond)
+			if got, want := len(exits), c.again; got != want {
+				t.Errorf("len(exits) = %v, want = %v", got, want)
+			}
+		})
+	}
+}
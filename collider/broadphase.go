@@ -0,0 +1,36 @@
+package collider
+
+import (
+	"github.com/downflux/go-database/agent"
+	"github.com/downflux/go-geometry/2d/hyperrectangle"
+)
+
+// Broadphase finds the set of candidate neighbors of an agent during a tick.
+//
+// By default, the collider uses the spatial index of the store as the broadphase, e.g. the database BVH.
+type Broadphase interface {
+	// Update is called serially at the start of every tick with the full
+	// set of agents in the tick, and should rebuild any internal state from
+	// the current agent positions. The input slice is owned by the
+	// collider, and must not be retained after the call.
+	Update(agents []agent.RO)
+
+	// Query appends into the input buffer all agents whose AABBs overlap
+	// the query rectangle and which pass the input filter, and returns the
+	// extended buffer. Query may be called concurrently, and the order of
+	// the returned agents is not significant.
+	Query(q hyperrectangle.R, filter func(a agent.RO) bool, buf []agent.RO) []agent.RO
+}
+
+// storephase adapts the spatial index of the store into a broadphase. The
+// store is kept in sync with agent positions by the collider, and therefore
+// does not need to be rebuilt every tick.
+type storephase struct {
+	s Querier
+}
+
+func (b storephase) Update(agents []agent.RO) {}
+
+func (b storephase) Query(q hyperrectangle.R, filter func(a agent.RO) bool, buf []agent.RO) []agent.RO {
+	return b.s.QueryAgents(q, filter, buf)
+}
@@ -1,18 +1,21 @@
 package collider
 
 import (
+	"context"
 	"fmt"
-	"sync"
+	"sort"
 	"time"
 
-	"github.com/downflux/go-collider/internal/kinematics"
+	"github.com/downflux/go-bvh/id"
+	"github.com/downflux/go-collider/kinematics"
 	"github.com/downflux/go-database/agent"
 	"github.com/downflux/go-database/database"
 	"github.com/downflux/go-database/feature"
-	"github.com/downflux/go-database/filters"
 	"github.com/downflux/go-database/projectile"
+	"github.com/downflux/go-geometry/2d/hyperrectangle"
 	"github.com/downflux/go-geometry/2d/vector"
 	"github.com/downflux/go-geometry/2d/vector/polar"
+	"github.com/downflux/go-geometry/epsilon"
 )
 
 var (
@@ -25,157 +28,772 @@ var (
 )
 
 type O struct {
+	// PoolSize is the number of long-lived worker goroutines owned by the
+	// collider.
 	PoolSize int
+
+	// OnStats is an optional hook which is called at the end of every
+	// tick with the execution statistics of the tick. Statistics are not
+	// collected if the hook is not set.
+	OnStats func(s Stats)
+
+	// Sleep enables sleep tracking, which skips velocity generation for
+	// idle agents, i.e. agents with zero velocity and target velocity which
+	// are not in contact with any moving agent. Sleeping agents are woken
+	// when a neighbor pushes into them, or when they are given a non-zero
+	// target velocity.
+	Sleep bool
+
+	// Broadphase is an optional spatial index which is used to find the
+	// neighbors of each agent, e.g. a Grid. If unset, the collider queries
+	// the store directly. Feature queries always use the store.
+	Broadphase Broadphase
+
+	// Pipeline is an optional ordered list of stages which generate the
+	// velocity and heading of each agent, e.g. in order to add custom
+	// speed modifiers. If unset, the collider uses DefaultPipeline. See
+	// DefaultPipeline for the stages which a custom pipeline must include
+	// for the corresponding options to take effect.
+	Pipeline []Stage
+
+	// AgentLayers and FeatureLayers are optional hooks which return the
+	// collision layers of an agent or feature, e.g. in order for air units
+	// to ignore ground features. Bodies collide only if their layers
+	// collide. If a hook is unset, the corresponding bodies are assigned
+	// DefaultLayers.
+	AgentLayers   func(a agent.RO) Layers
+	FeatureLayers func(f feature.RO) Layers
+
+	// FilterAgent and FilterFeature are optional hooks which return true
+	// if the agent a should collide with the input neighbor or feature,
+	// e.g. in order for ghost units to pass through allied units. The
+	// hooks are only consulted for bodies which pass the built-in
+	// collision filters and the layer check, and are called concurrently.
+	//
+	// N.B.: Filters which are not symmetric may cause one agent to push
+	// into another agent which does not yield.
+	FilterAgent   func(a agent.RO, b agent.RO) bool
+	FilterFeature func(a agent.RO, f feature.RO) bool
+
+	// OnSquish is an optional hook which is called at the end of every
+	// tick with the agents which are being run over by larger agents, e.g.
+	// in order to damage infantry run over by vehicles. Overlaps are
+	// detected at the start of the tick, i.e. after the movement of the
+	// previous tick. The hook is only called if there is at least one
+	// event, and events are sorted by agent ID. The input slice is owned by
+	// the collider, and must not be retained after the call.
+	OnSquish func(es []Squish)
+
+	// Shape is an optional hook which returns the shape of an agent, e.g.
+	// an oriented box for vehicles. Collisions with shaped agents use the
+	// heading of the agent, and an agent is not allowed to turn into a
+	// neighbor or feature. If unset, agents are circles of the agent
+	// radius.
+	//
+	// The agent radius is still used as the bounding radius of the agent
+	// for neighbor and feature queries, and the shape of the agent must
+	// therefore fit within the radius at any heading.
+	Shape func(a agent.RO) kinematics.Shape
+
+	// JamTicks enables jam tracking, and is the number of consecutive
+	// ticks an agent must want to move without moving before the agent is
+	// considered jammed. Jammed agents are resolved by the Unjam pipeline
+	// stage with the input JamStrategy. See JamStrategy for more
+	// information.
+	JamTicks    int
+	JamStrategy JamStrategy
+
+	// OnJam is an optional hook which is called at the end of every tick
+	// with the agents which are jammed, if jam tracking is enabled. The
+	// hook is only called if there is at least one jammed agent, and
+	// agents are sorted by ID. The input slice is owned by the collider,
+	// and must not be retained after the call.
+	OnJam func(as []agent.RO)
+
+	// Priority is an optional hook which returns the right of way of an
+	// agent, e.g. in order for units carrying cargo to keep their path.
+	// An agent steps aside for a neighbor with a strictly higher priority
+	// which wants to move into the agent. Agents with equal priority do
+	// not yield to one another. The hook is called concurrently.
+	Priority func(a agent.RO) int
+
+	// Nudge enables idle agents, i.e. agents with zero target velocity, to
+	// step aside for moving teammates which want to move into them.
+	//
+	// N.B.: Sleeping agents are woken at the end of the tick in which they
+	// are pushed, and therefore step aside one tick later than awake
+	// agents.
+	Nudge bool
+
+	// FeatureAltitude is an optional hook which returns the altitude of a
+	// feature, e.g. in order for tall buildings to block air units. If
+	// unset, features occupy the altitude of their terrain flags. Agents
+	// occupy the altitude of their terrain flags unless set via
+	// SetAltitude. Bodies collide only if they share an altitude.
+	FeatureAltitude func(f feature.RO) Altitude
+
+	// OnAltitude is an optional hook which is called once a tick has been
+	// committed for each requested altitude transition applied in the
+	// tick.
+	OnAltitude func(a agent.RO, k Altitude)
+
+	// Wrap is an optional rectangle which makes the world wrap around,
+	// i.e. agents and projectiles which leave one edge of the rectangle
+	// reappear on the opposite edge, and agents near an edge collide with
+	// agents and features near the opposite edge. Bodies should start
+	// inside the rectangle.
+	//
+	// Neighbors and features across an edge are passed to the pipeline
+	// and hooks as images, whose positions and AABBs are shifted next to
+	// the current agent. Images are only valid for the duration of the
+	// call.
+	//
+	// N.B.: Joints do not account for the wrapped distance between their
+	// ends.
+	Wrap *hyperrectangle.R
+
+	// Bounds is an optional rectangle which confines the agents of the
+	// world, e.g. in place of border features along the edges of the map.
+	// Agents are stopped at the edges by the ClampBounds pipeline stage.
+	// Bounds and Wrap are mutually exclusive.
+	//
+	// N.B.: The agent radius is used as the extent of shaped agents.
+	Bounds *hyperrectangle.R
+
+	// OnExit is an optional hook which is called at the end of every tick
+	// with the projectiles which are outside the bounds of the world at the
+	// end of the tick, i.e. projectiles which left the bounds during the
+	// tick, along with projectiles which were already outside the bounds,
+	// e.g. because they were inserted outside. Projectiles which are not
+	// despawned are reported again in every tick in which they remain
+	// outside. The hook is only called if there is at least one
+	// projectile, and projectiles are sorted by ID. The input slice is
+	// owned by the collider, and must not be retained after the call.
+	OnExit func(ps []projectile.RO)
+
+	// Despawn deletes projectiles which are outside the bounds of the world
+	// from the store at the end of the tick, after OnExit is called. The
+	// store must implement Despawner. Database-backed colliders support
+	// despawning.
+	Despawn bool
+
+	// FeatureMotion is an optional hook which returns the motion of a
+	// feature over the current tick, e.g. for doors, lifts, and moving
+	// platforms. Agents are filtered against their velocity relative to
+	// the feature, and may be pushed or carried by the feature. The hook
+	// is called concurrently. If unset, features do not move.
+	//
+	// The collider does not move features, as features are immutable in
+	// the store; the caller moves each feature by its velocity once the
+	// tick has been committed.
+	//
+	// Sleeping agents in contact with a moving feature, or which stand on
+	// a moving platform, are woken. The committed velocity of a carried
+	// agent is relative to its platform.
+	FeatureMotion func(f feature.RO) Motion
+
+	// Sensor is an optional hook which returns true if the input feature
+	// is a sensor, e.g. a capture point or a vision trigger. Sensors never
+	// block agents, and instead report the agents which enter or leave the
+	// sensor via OnTrigger. Agents are only inside sensors which share an
+	// altitude with the agent. The hook is called concurrently.
+	Sensor func(f feature.RO) bool
+
+	// OnTrigger is an optional hook which is called at the end of every
+	// tick with the agents which entered or left a sensor during the tick,
+	// sorted by agent ID and then sensor ID. An agent is inside a sensor
+	// if the agent overlaps the sensor at the end of the tick. An agent
+	// which passes through a sensor within a single tick, e.g. a fast
+	// agent crossing a thin tripwire, both enters and leaves the sensor in
+	// that tick, in that order. The hook is only called if there is at
+	// least one event. The input slice is owned by the collider, and must
+	// not be retained after the call.
+	OnTrigger func(ts []Trigger)
 }
 
 type C struct {
-	db       *database.DB
-	poolSize int
+	store      Store
+	broadphase Broadphase
+	pipeline   []Stage
+
+	// sleep tracks sleeping agents across ticks, and is nil if sleep
+	// tracking is disabled.
+	sleep *sleep
+
+	// joints is the set of joints between agents.
+	joints *joints
+
+	// altitudes tracks the altitudes of agents across ticks.
+	altitudes *altitudes
+
+	// torus is the wrap-around topology of the world, and is nil if the
+	// world does not wrap.
+	torus *torus
+
+	// bounds confines the agents of the world, and is nil if the world is
+	// unbounded.
+	bounds *bounds
+
+	// despawner deletes projectiles which leave the bounds, and is nil if
+	// despawning is disabled.
+	despawner Despawner
+
+	// sensors tracks the agents inside each sensor across ticks, and is nil
+	// if trigger events are disabled.
+	sensors *sensors
+
+	// jams tracks jammed agents across ticks, and is nil if jam tracking
+	// is disabled.
+	jams *jams
+
+	pool   *pool
+	ranges *ranges
+
+	// work and commit are the worker tasks bound to the collider, and are
+	// stored in order to avoid allocating new method values every tick.
+	work    func(worker int)
+	commit  func(worker int)
+	workers []*worker
+
+	// ctx, d, and schedule are the parameters of the current tick, and
+	// are read by the workers.
+	ctx      context.Context
+	d        time.Duration
+	schedule Schedule
+
+	// agents, projectiles, ams, and pms are per-tick buffers which are
+	// reused between ticks. The generated position, velocity, and heading
+	// vectors reference the ps, vs, and hs buffers respectively.
+	agents      []agent.RO
+	projectiles []projectile.RO
+	ams         []AgentResult
+	pms         []ProjectileResult
+	ps          []float64
+	vs          []float64
+	hs          []float64
+
+	// squishes is the per-tick buffer of squish events.
+	squishes squishes
+
+	shape    func(a agent.RO) kinematics.Shape
+	priority func(a agent.RO) int
+	nudge    bool
+
+	onStats    func(s Stats)
+	onSquish   func(es []Squish)
+	onJam      func(as []agent.RO)
+	onAltitude func(a agent.RO, k Altitude)
+	onExit     func(ps []projectile.RO)
+
+	motion    func(f feature.RO) Motion
+	onTrigger func(ts []Trigger)
 }
 
-func New(db *database.DB, o O) *C {
+const (
+	// chunkSize is the number of agents a worker claims at a time.
+	chunkSize = 64
+)
+
+// New constructs a collider over the input database and starts its worker
+// pool. The caller should call Close once the collider is no longer needed in
+// order to release the workers.
+//
+// N.B.: The database allocates on every listing, query, and position update,
+// and so ticks over a collider constructed by New are not allocation-free. Use
+// NewStore with a store which reuses the input buffers if ticks must not
+// allocate.
+func New(db *database.DB, o O) *C { return NewStore((*dbstore)(db), o) }
+
+// NewStore constructs a collider over the input store and starts its worker
+// pool. NewStore allows the collider to run directly against external storage,
+// e.g. the component storage of an ECS, without mirroring bodies into a
+// database.
+func NewStore(s Store, o O) *C {
 	if o.PoolSize < 2 {
 		panic(fmt.Sprintf("PoolSize specified %v is smaller than the minimum value of 2", o.PoolSize))
 	}
-	return &C{
-		db:       db,
-		poolSize: o.PoolSize,
+
+	c := &C{
+		store:      s,
+		pool:       newPool(o.PoolSize),
+		ranges:     newRanges(o.PoolSize, chunkSize),
+		workers:    make([]*worker, o.PoolSize),
+		onStats:    o.OnStats,
+		onSquish:   o.OnSquish,
+		onJam:      o.OnJam,
+		onAltitude: o.OnAltitude,
+		onExit:     o.OnExit,
+		motion:     o.FeatureMotion,
+		onTrigger:  o.OnTrigger,
+		joints:     newJoints(),
+		altitudes:  newAltitudes(o.FeatureAltitude),
+		shape:      o.Shape,
+		priority:   o.Priority,
+		nudge:      o.Nudge,
+	}
+	if c.broadphase = o.Broadphase; c.broadphase == nil {
+		c.broadphase = storephase{s: s}
+	}
+	if c.pipeline = append([]Stage(nil), o.Pipeline...); o.Pipeline == nil {
+		c.pipeline = append([]Stage(nil), DefaultPipeline...)
+	}
+	if o.Sleep {
+		c.sleep = newSleep(c.joints)
 	}
+	if o.Wrap != nil && o.Bounds != nil {
+		panic("Wrap and Bounds cannot both be specified")
+	}
+	if o.Wrap != nil {
+		c.torus = newTorus(*o.Wrap)
+	}
+	if o.Bounds != nil {
+		c.bounds = newBounds(*o.Bounds)
+	}
+	if (o.OnExit != nil || o.Despawn) && o.Bounds == nil {
+		panic("OnExit and Despawn require Bounds to be specified")
+	}
+	if o.Despawn {
+		d, ok := s.(Despawner)
+		if !ok {
+			panic(fmt.Sprintf("store %T does not support despawning projectiles", s))
+		}
+		c.despawner = d
+	}
+	if o.OnTrigger != nil {
+		if o.Sensor == nil {
+			panic("OnTrigger requires Sensor to be specified")
+		}
+		c.sensors = newSensors()
+	}
+	if o.JamTicks != 0 {
+		c.jams = newJams(o.JamTicks, o.JamStrategy)
+	}
+	f := filter{
+		agentLayers:   o.AgentLayers,
+		featureLayers: o.FeatureLayers,
+		agent:         o.FilterAgent,
+		feature:       o.FilterFeature,
+		joints:        c.joints,
+		altitudes:     c.altitudes,
+		motion:        o.FeatureMotion,
+		sensor:        o.Sensor,
+	}
+	for i := range c.workers {
+		c.workers[i] = newWorker(f)
+	}
+	c.work = c.generateWorker
+	c.commit = c.commitWorker
+	return c
 }
 
-func (c *C) generate(d time.Duration) ([]am, []pm) {
-	ams := make([]am, 0, 256)
-	pms := make([]pm, 0, 256)
+// InsertJoint adds a joint between two agents, and returns the ID of the joint.
+// Joints must not be modified during a tick.
+func (c *C) InsertJoint(j Joint) id.ID { return c.joints.insert(j) }
 
-	amsch := make(chan am, 256)
-	pmsch := make(chan pm, 256)
+// DeleteJoint removes the joint with the input ID.
+func (c *C) DeleteJoint(x id.ID) { c.joints.delete(x) }
 
-	go func(amsch chan<- am, pmsch chan<- pm) {
-		var wg sync.WaitGroup
+// Close stops the worker pool. The collider must not be used after it has been
+// closed.
+func (c *C) Close() { c.pool.close() }
 
-		wg.Add(c.poolSize)
-		go func(ch chan<- pm) {
-			defer wg.Done()
-			for p := range c.db.ListProjectiles() {
-				pmsch <- pm{
-					projectile: p,
-					v:          p.TargetVelocity(),
-					h:          polar.Polar(vector.Unit(p.TargetVelocity())),
-				}
+// generate computes the next tick velocities and headings of all agents and
+// projectiles in the store. Agents are advanced by the input schedule if
+// set, and by d otherwise. If the input context is cancelled during
+// generation, workers stop claiming new agents, and the returned results are
+// incomplete.
+//
+// The returned slices are owned by the collider and are only valid until the
+// next call to generate.
+func (c *C) generate(ctx context.Context, d time.Duration, s Schedule) ([]AgentResult, []ProjectileResult, Stats) {
+	c.ctx, c.d, c.schedule = ctx, d, s
+	defer func() { c.ctx, c.schedule = nil, nil }()
+
+	if c.bounds != nil {
+		for i := range c.bounds.exits {
+			c.bounds.exits[i] = nil
+		}
+		c.bounds.exits = c.bounds.exits[:0]
+	}
+
+	c.agents = c.store.Agents(c.agents[:0])
+	c.projectiles = c.store.Projectiles(c.projectiles[:0])
+	c.joints.update(c.agents)
+	c.broadphase.Update(c.agents)
+	if c.torus != nil {
+		c.torus.update(c.agents)
+	}
+	c.stage(c.workers[0])
+	if c.sleep != nil {
+		c.sleep.update(c.agents)
+	}
+
+	n := len(c.agents)
+	m := len(c.projectiles)
+	if cap(c.ams) < n {
+		c.ams = make([]AgentResult, n)
+	}
+	c.ams = c.ams[:n]
+	if cap(c.pms) < m {
+		c.pms = make([]ProjectileResult, m)
+	}
+	c.pms = c.pms[:m]
+
+	// Projectile positions and headings share the buffers with agents.
+	if cap(c.ps) < 2*(n+m) {
+		c.ps = make([]float64, 2*(n+m))
+	}
+	if cap(c.vs) < 2*n {
+		c.vs = make([]float64, 2*n)
+	}
+	if cap(c.hs) < 2*(n+m) {
+		c.hs = make([]float64, 2*(n+m))
+	}
+	c.ps = c.ps[:2*(n+m)]
+	c.vs = c.vs[:2*n]
+	c.hs = c.hs[:2*(n+m)]
+
+	stats := Stats{
+		Projectiles: m,
+	}
+
+	for i, p := range c.projectiles {
+		q := vector.M(c.ps[2*(n+i) : 2*(n+i)+2 : 2*(n+i)+2])
+		h := polar.M(c.hs[2*(n+i) : 2*(n+i)+2 : 2*(n+i)+2])
+
+		heading(p.TargetVelocity(), h)
+		advance(p.Position(), p.TargetVelocity(), d, q)
+		if c.torus != nil {
+			c.torus.wrap(q)
+		}
+		if c.bounds != nil {
+			c.bounds.exit(p, q.V())
+		}
+		c.pms[i] = ProjectileResult{
+			Projectile: p,
+			Position:   q.V(),
+			Velocity:   p.TargetVelocity(),
+			Heading:    h.V(),
+		}
+	}
+
+	c.reserve()
+	c.ranges.reset(n)
+	c.pool.run(c.work)
+
+	for _, w := range c.workers {
+		stats.merge(w.stats)
+	}
+
+	return c.ams, c.pms, stats
+}
+
+// generateWorker is run by each worker in the pool, and generates velocities
+// for the agents claimed by the worker.
+func (c *C) generateWorker(worker int) {
+	w := c.workers[worker]
+	w.stats = Stats{}
+	w.w.enabled = c.onStats != nil
+	w.naps = w.naps[:0]
+	w.contacts = w.contacts[:0]
+	w.squishes = w.squishes[:0]
+	for i := range w.sensed {
+		w.sensed[i] = occupancy{}
+	}
+	w.sensed = w.sensed[:0]
+	for i := range w.crossed {
+		w.crossed[i] = occupancy{}
+	}
+	w.crossed = w.crossed[:0]
+
+	for c.ctx.Err() == nil {
+		lo, hi, ok := c.ranges.claim(worker)
+		if !ok {
+			break
+		}
+		for i := lo; i < hi; i++ {
+			p := vector.M(c.ps[2*i : 2*i+2 : 2*i+2])
+			v := vector.M(c.vs[2*i : 2*i+2 : 2*i+2])
+			h := polar.M(c.hs[2*i : 2*i+2 : 2*i+2])
+
+			a := c.agents[i]
+
+			d, ok := c.d, true
+			if c.schedule != nil {
+				d, ok = c.schedule(a)
+			}
+			if ok && c.onSquish != nil {
+				c.squish(w, a)
 			}
-			close(ch)
-		}(pmsch)
-
-		in := c.db.ListAgents()
-		for i := 0; i < c.poolSize-1; i++ {
-			go func(out chan<- am) {
-				defer wg.Done()
-				for a := range in {
-					v := vector.M{0, 0}
-					v.Copy(a.TargetVelocity())
-
-					aabb := a.AABB()
-					ns := c.db.QueryAgents(aabb, func(b agent.RO) bool {
-						return filters.AgentIsCollidingNotSquishable(a, b)
-					})
-					fs := c.db.QueryFeatures(aabb, func(f feature.RO) bool {
-						return filters.AgentIsCollidingWithFeature(a, f)
-					})
-
-					for _, f := range fs {
-						kinematics.SetFeatureCollisionVelocity(a, f, v)
-					}
-
-					// Check for collisions which the agent
-					// cares about, e.g. care about
-					// squishability. These functions set
-					// the input vector v to ensure that the
-					// normal components of the velocity is
-					// filtered out for each individual
-					// entity. However, this method is not
-					// always reliable, and a multi-body
-					// collision may flip the velocity back
-					// into the body of an existing entity.
-					for _, n := range ns {
-						kinematics.SetCollisionVelocity(a, n, v)
-					}
-
-					kinematics.ClampVelocity(a, v)
-					kinematics.ClampAcceleration(a, v, d)
-
-					// N.B.: The velocity can be further reduced to
-					// zero here due to the physical limitations of
-					// the agent.
-					h := polar.M{0, 0}
-					h.Copy(a.Heading())
-					kinematics.ClampHeading(a, d, v, h)
-
-					// Second pass ensures agent is not
-					// colliding with any static features
-					// after the velocity manipulations.
-					for _, f := range fs {
-						kinematics.ClampFeatureCollisionVelocity(a, f, v)
-					}
-
-					// Second pass across neighbors forces
-					// the velocity to zero if a velocity
-					// has flip-flopped back into the
-					// forbidden zone of another agent.
-					for _, n := range ns {
-						kinematics.ClampCollisionVelocity(a, n, v)
-					}
-
-					out <- am{
-						agent: a,
-						v:     v.V(),
-						h:     h.V(),
-					}
+			if !ok || (c.sleep != nil && c.sleep.isAsleep(i) && !c.isMoved(w, a)) {
+				if ok {
+					w.stats.Sleeping++
+				} else {
+					w.stats.Deferred++
+				}
+
+				p.Copy(a.Position())
+				v.Copy(a.Velocity())
+				h.Copy(a.Heading())
+				c.ams[i] = AgentResult{
+					Agent:    a,
+					Position: p.V(),
+					Velocity: v.V(),
+					Heading:  h.V(),
+					Skipped:  true,
 				}
-			}(amsch)
+				if c.sensors != nil {
+					c.sense(w, a, p.V(), p.V())
+				}
+				continue
+			}
+
+			c.generateAgent(w, a, d, v, h)
+			if c.sleep != nil {
+				c.sleep.observe(w, i, a, v.V(), w.ns)
+			}
+
+			advance(a.Position(), v.V(), d, p)
+			if c.sensors != nil {
+				c.sense(w, a, a.Position(), p.V())
+			}
+			if c.torus != nil {
+				c.torus.wrap(p)
+			}
+			moved := !isZero(v.V())
+
+			// The committed velocity of a carried agent is relative
+			// to its platform, so that the platform velocity is not
+			// carried over into the next tick. A carried agent which
+			// was stopped after the platform velocity was added,
+			// e.g. by a wall, is at rest.
+			if st := &w.state; st.carried && !(st.moving && !moved) {
+				v.Sub(st.carry)
+			}
+			c.ams[i] = AgentResult{
+				Agent:    a,
+				Position: p.V(),
+				Velocity: v.V(),
+				Heading:  h.V(),
+				Moved:    moved,
+			}
 		}
+	}
 
-		wg.Wait()
-		close(amsch)
+	w.a = nil
+	w.state = State{}
+}
 
-	}(amsch, pmsch)
+// advance sets the input position buffer to the position of an entity after
+// moving with the input velocity for the input duration.
+func advance(p vector.V, v vector.V, d time.Duration, buf vector.M) {
+	t := float64(d) / float64(time.Second)
+	buf.SetX(p.X() + t*v.X())
+	buf.SetY(p.Y() + t*v.Y())
+}
 
-	for r := range amsch {
-		ams = append(ams, r)
+// commitWorker is run by each worker in the pool, and commits the generated
+// velocities and headings of the agents claimed by the worker into the store.
+func (c *C) commitWorker(worker int) {
+	for {
+		lo, hi, ok := c.ranges.claim(worker)
+		if !ok {
+			return
+		}
+		c.store.SetAgents(c.ams[lo:hi])
 	}
-	for r := range pmsch {
-		pms = append(pms, r)
+}
+
+// apply commits the generated tick into the store.
+//
+// Velocities and headings do not affect the spatial index of the store, and
+// are committed in parallel. Positions are then committed in a single batch.
+func (c *C) apply(ams []AgentResult, pms []ProjectileResult) {
+	c.ranges.reset(len(ams))
+	c.pool.run(c.commit)
+
+	c.store.MoveAgents(ams)
+	c.store.SetProjectiles(pms)
+}
+
+// generateAgent runs the pipeline to compute the velocity and heading of a
+// single agent after advancing the agent by the input duration, and writes the
+// results into the input velocity and heading buffers. Execution statistics are
+// recorded into the worker.
+func (c *C) generateAgent(w *worker, a agent.RO, d time.Duration, v vector.M, h polar.M) {
+	s := &w.stats
+	w.w.lap()
+
+	v.Copy(a.TargetVelocity())
+
+	w.set(a)
+	c.queryAgents(w, w.filterAgent)
+	s.NeighborQuery += w.w.lap()
+
+	carry := c.carrier(w)
+	if c.motion != nil {
+		w.extend(reach)
 	}
+	c.queryFeatures(w, w.filterFeature)
+	s.FeatureQuery += w.w.lap()
+
+	ns, fs := w.ns, w.fs
 
-	return ams, pms
+	s.Agents++
+	s.NeighborQueries++
+	s.FeatureQueries++
+	s.Neighbors += len(ns)
+	s.Features += len(fs)
+	if len(ns) > s.MaxNeighbors {
+		s.MaxNeighbors = len(ns)
+	}
+
+	// The pipeline stages are order dependent, and the order of the
+	// query results depends on the shape of the underlying BVH, which in
+	// turn depends on the (random) order in which agents were updated in
+	// previous ticks. Sort the results to ensure the simulation is
+	// deterministic, which is necessary for e.g. replaying recorded ticks.
+	sortAgents(ns)
+	sortFeatures(fs)
+
+	h.Copy(a.Heading())
+
+	st := &w.state
+	*st = State{
+		Agent:     a,
+		Duration:  d,
+		Neighbors: ns,
+		Features:  fs,
+		Velocity:  v,
+		Heading:   h,
+		joints:    c.joints,
+		jams:      c.jams,
+		bounds:    c.bounds,
+		motion:    c.motion,
+		carry:     carry,
+		priority:  c.priority,
+		nudge:     c.nudge,
+	}
+	if c.shape != nil {
+		st.shape = c.shape
+		st.body = c.place(a)
+	}
+	for _, stage := range c.pipeline {
+		stage.Apply(st)
+	}
+	if st.clamped {
+		s.Clamped++
+	}
+	s.Kinematics += w.w.lap()
+}
+
+// place returns the body of the input agent with the user-specified shape.
+func (c *C) place(a agent.RO) kinematics.Body {
+	b := kinematics.Place(a, c.shape(a))
+	if r := b.Bound(); r > a.Radius() && !epsilon.Within(r, a.Radius()) {
+		panic(fmt.Sprintf("shape of agent %v has a bounding radius %v which is larger than the agent radius %v", a.ID(), r, a.Radius()))
+	}
+	return b
 }
 
 // Tick advances the world by one tick. During this execution, agents must not
-// be modified by the user.
-func (c *C) Tick(d time.Duration) {
-	t := float64(d) / float64(time.Second)
+// be modified by the user. Tick must not be called concurrently on the same
+// collider.
+func (c *C) Tick(d time.Duration) { c.TickContext(context.Background(), d) }
+
+// TickContext advances the world by one tick, and stops generating new agent
+// velocities if the input context is done before all velocities have been
+// generated. The store is updated only if the tick was fully generated,
+// i.e. the tick is either applied in full or not at all.
+//
+// TickContext returns a non-nil error if the tick was not applied. The error
+// wraps the context error, and may be checked with e.g.
+//
+//	errors.Is(err, context.DeadlineExceeded)
+//
+// Once velocity generation completes, the commit phase is not interruptible,
+// and may finish after the context deadline.
+func (c *C) TickContext(ctx context.Context, d time.Duration) error {
+	return c.TickSchedule(ctx, d, nil)
+}
+
+// Schedule returns the duration by which the input agent should be advanced in
+// the current tick. Agents for which the schedule returns false are not
+// advanced, and act as stationary obstacles to the other agents in the tick.
+//
+// The schedule is called concurrently, and must not modify the store.
+type Schedule func(a agent.RO) (time.Duration, bool)
+
+// TickSchedule advances each agent by the duration returned by the input
+// schedule, and advances all projectiles by d. This allows agents to be ticked
+// at different rates, e.g. by a level-of-detail scheduler. A nil schedule
+// advances all agents by d.
+//
+// See TickContext for the cancellation semantics of TickSchedule.
+func (c *C) TickSchedule(ctx context.Context, d time.Duration, s Schedule) error {
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("tick was not started: %w", err)
+	}
+
+	w := stopwatch{enabled: c.onStats != nil}
+	w.lap()
+
+	ams, pms, stats := c.generate(ctx, d, s)
+	stats.Generate = w.lap()
 
-	ams, pms := c.generate(d)
-	// Concurrent BVH ams is not supported.
+	if err := ctx.Err(); err != nil {
+		c.altitudes.discard()
+		return fmt.Errorf("tick was abandoned after generating velocities for %v of %v agents: %w", stats.Agents+stats.Sleeping+stats.Deferred, len(ams), err)
+	}
+
+	c.apply(ams, pms)
+	if c.sleep != nil {
+		c.sleep.settle(c.agents, c.workers)
+	}
+	c.altitudes.commit(c.onAltitude, c.agents)
 	for _, r := range ams {
-		c.db.SetAgentPosition(r.agent.ID(), vector.Add(r.agent.Position(), vector.Scale(t, r.v)))
-		c.db.SetAgentHeading(r.agent.ID(), r.h)
-		c.db.SetAgentVelocity(r.agent.ID(), r.v)
+		if r.Moved {
+			stats.Moved++
+		}
 	}
-	for _, r := range pms {
-		c.db.SetProjectilePosition(r.projectile.ID(), vector.Add(r.projectile.Position(), vector.Scale(t, r.v)))
-		c.db.SetProjectileHeading(r.projectile.ID(), r.h)
-		c.db.SetProjectileVelocity(r.projectile.ID(), r.v)
+	stats.Apply = w.lap()
+
+	if c.onSquish != nil {
+		c.squishes = c.squishes[:0]
+		for _, w := range c.workers {
+			c.squishes = append(c.squishes, w.squishes...)
+		}
+		if len(c.squishes) > 0 {
+			sort.Sort(&c.squishes)
+			c.onSquish(c.squishes)
+		}
 	}
-}
 
-type pm struct {
-	projectile projectile.RO
-	v          vector.V
-	h          polar.V
-}
+	if c.jams != nil {
+		if as := c.jams.update(ams); len(as) > 0 && c.onJam != nil {
+			c.onJam(as)
+		}
+	}
 
-type am struct {
-	agent agent.RO
-	v     vector.V
-	h     polar.V
+	if c.sensors != nil {
+		if ts := c.sensors.update(c.workers); len(ts) > 0 {
+			c.onTrigger(ts)
+		}
+	}
+
+	if c.bounds != nil && len(c.bounds.exits) > 0 {
+		sort.Sort(&c.bounds.exits)
+		if c.onExit != nil {
+			c.onExit(c.bounds.exits)
+		}
+		if c.despawner != nil {
+			c.despawner.DeleteProjectiles(c.bounds.exits)
+		}
+	}
+
+	if c.onStats != nil {
+		stats.Total = stats.Generate + stats.Apply
+		c.onStats(stats)
+	}
+	return nil
 }
@@ -1,16 +1,22 @@
 package collider
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"math"
 	"math/rand"
+	"sort"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/downflux/go-bvh/id"
+	"github.com/downflux/go-collider/kinematics"
 	"github.com/downflux/go-database/agent"
 	"github.com/downflux/go-database/database"
 	"github.com/downflux/go-database/feature"
+	"github.com/downflux/go-database/filters"
 	"github.com/downflux/go-database/flags/size"
 	"github.com/downflux/go-database/projectile"
 	"github.com/downflux/go-geometry/2d/hyperrectangle"
@@ -268,53 +274,618 @@ func BenchmarkTick(b *testing.B) {
 		}
 	}
 
+	// variant is a modification of the benchmark world. The unnamed
+	// variant is the original world, i.e. the default collider options
+	// with features along the world borders, and is kept unchanged so
+	// that results remain comparable across versions.
+	type variant struct {
+		name string
+
+		// o returns the collider options for a world spanning [min, max]
+		// along both axes, and borders indicates the world is enclosed by
+		// border features.
+		o       func(min, max float64) O
+		borders bool
+	}
+
+	variants := []variant{
+		{
+			name:    "",
+			o:       func(min, max float64) O { return DefaultO },
+			borders: true,
+		},
+		{
+			name: "Grid",
+			o: func(min, max float64) O {
+				return O{PoolSize: DefaultO.PoolSize, Broadphase: NewGrid(2 * R)}
+			},
+			borders: true,
+		},
+		{
+			name: "Bounds",
+			o: func(min, max float64) O {
+				return O{
+					PoolSize: DefaultO.PoolSize,
+					Bounds:   hyperrectangle.New(vector.V{min, min}, vector.V{max, max}),
+				}
+			},
+			borders: false,
+		},
+	}
+
 	for _, c := range configs {
-		b.Run(c.name, func(b *testing.B) {
-			b.StopTimer()
-			area := float64(c.n) * math.Pi * R * R / c.coverage
-			min := 0.0
-			max := math.Sqrt(area)
+		for _, v := range variants {
+			name := c.name
+			if v.name != "" {
+				name = fmt.Sprintf("%v/%v", c.name, v.name)
+			}
+			b.Run(name, func(b *testing.B) {
+				b.StopTimer()
+				area := float64(c.n) * math.Pi * R * R / c.coverage
+				min := 0.0
+				max := math.Sqrt(area)
+
+				db := database.New(database.DefaultO)
+				collider := New(db, v.o(min, max))
+				defer collider.Close()
+
+				for i := 0; i < c.n; i++ {
+					db.InsertAgent(agent.O{
+						Radius:             R,
+						Mass:               1,
+						Position:           rv(min, max),
+						TargetPosition:     vector.V{0, 0},
+						TargetVelocity:     rv(-1, 1),
+						Velocity:           rv(-1, 1),
+						MaxVelocity:        60,
+						MaxAcceleration:    10,
+						MaxAngularVelocity: math.Pi / 4,
+						Heading:            polar.V{1, 0},
+						Size:               size.FSmall,
+					})
+				}
+
+				if v.borders {
+					// Add world borders.
+					// Add xmin border.
+					db.InsertFeature(feature.O{
+						AABB: *hyperrectangle.New(vector.V{min - 1, min - 1}, vector.V{min, max + 1}),
+					})
+					// Add xmax border.
+					db.InsertFeature(feature.O{
+						AABB: *hyperrectangle.New(vector.V{max, min - 1}, vector.V{max + 1, max + 1}),
+					})
+					// Add ymin border.
+					db.InsertFeature(feature.O{
+						AABB: *hyperrectangle.New(vector.V{min, min - 1}, vector.V{max, min}),
+					})
+					// Add ymax border.
+					db.InsertFeature(feature.O{
+						AABB: *hyperrectangle.New(vector.V{min, max}, vector.V{max, max + 1}),
+					})
+				}
+
+				b.ReportAllocs()
+				b.StartTimer()
+				for i := 0; i < b.N; i++ {
+					collider.Tick(33 * time.Millisecond)
+				}
+			})
+		}
+	}
+}
+
+func TestTickStats(t *testing.T) {
+	var got Stats
+
+	db := database.New(database.DefaultO)
+	collider := New(db, O{
+		PoolSize: DefaultO.PoolSize,
+		OnStats:  func(s Stats) { got = s },
+	})
+	defer collider.Close()
+
+	for _, p := range []vector.V{{10, 10}, {10, 11.5}, {10, 13}, {20, 20}} {
+		db.InsertAgent(agent.O{
+			Position:        p,
+			TargetPosition:  vector.V{0, 0},
+			TargetVelocity:  vector.V{0, 1},
+			Velocity:        vector.V{0, 1},
+			MaxVelocity:     1,
+			MaxAcceleration: 1,
+			Heading:         polar.V{1, math.Pi / 2},
+			Radius:          1,
+			Mass:            1,
+			Size:            size.FSmall,
+		})
+	}
+	db.InsertFeature(feature.O{
+		AABB: *hyperrectangle.New(vector.V{20.5, 20.5}, vector.V{30, 30}),
+	})
+	db.InsertProjectile(projectile.O{
+		Position:       vector.V{0, 0},
+		TargetPosition: vector.V{0, 0},
+		TargetVelocity: vector.V{0, -1},
+		Velocity:       vector.V{0, -1},
+		Heading:        polar.V{1, 3 * math.Pi / 2},
+		Radius:         1,
+	})
 
+	collider.Tick(100 * time.Millisecond)
+
+	want := Stats{
+		Agents:          4,
+		Projectiles:     1,
+		NeighborQueries: 4,
+		FeatureQueries:  4,
+		// The middle agent collides with both neighbors, and the
+		// boundary agents collide with the middle agent.
+		Neighbors:    4,
+		Features:     1,
+		MaxNeighbors: 2,
+		// The bottom and middle agents are forced to stop by the
+		// agent directly in front of them, and the agent at the
+		// feature corner cannot turn to slide past the feature.
+		Clamped: 3,
+		// Only the front agent continues moving.
+		Moved: 1,
+	}
+	if got.Total <= 0 || got.Generate <= 0 || got.Apply <= 0 || got.Total < got.Generate {
+		t.Errorf("Stats() = %+v, want non-zero phase durations", got)
+	}
+	got.Total, got.Generate, got.Apply = 0, 0, 0
+	got.NeighborQuery, got.FeatureQuery, got.Kinematics = 0, 0, 0
+	if got != want {
+		t.Errorf("Stats() = %+v, want = %+v", got, want)
+	}
+}
+
+func TestTickContext(t *testing.T) {
+	type config struct {
+		name string
+		ctx  func() (context.Context, context.CancelFunc)
+		want error
+	}
+
+	configs := []config{
+		{
+			name: "Background",
+			ctx: func() (context.Context, context.CancelFunc) {
+				return context.WithCancel(context.Background())
+			},
+			want: nil,
+		},
+		{
+			name: "Cancelled",
+			ctx: func() (context.Context, context.CancelFunc) {
+				ctx, cancel := context.WithCancel(context.Background())
+				cancel()
+				return ctx, cancel
+			},
+			want: context.Canceled,
+		},
+		{
+			name: "DeadlineExceeded",
+			ctx: func() (context.Context, context.CancelFunc) {
+				return context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+			},
+			want: context.DeadlineExceeded,
+		},
+	}
+
+	for _, c := range configs {
+		t.Run(c.name, func(t *testing.T) {
 			db := database.New(database.DefaultO)
 			collider := New(db, DefaultO)
-			for i := 0; i < c.n; i++ {
-				db.InsertAgent(agent.O{
-					Radius:             R,
-					Mass:               1,
-					Position:           rv(min, max),
-					TargetPosition:     vector.V{0, 0},
-					TargetVelocity:     rv(-1, 1),
-					Velocity:           rv(-1, 1),
-					MaxVelocity:        60,
-					MaxAcceleration:    10,
-					MaxAngularVelocity: math.Pi / 4,
-					Heading:            polar.V{1, 0},
-					Size:               size.FSmall,
-				})
-			}
+			defer collider.Close()
 
-			// Add world borders.
-			// Add xmin border.
-			db.InsertFeature(feature.O{
-				AABB: *hyperrectangle.New(vector.V{min - 1, min - 1}, vector.V{min, max + 1}),
+			a := db.InsertAgent(agent.O{
+				Position:       vector.V{10, 10},
+				TargetPosition: vector.V{0, 0},
+				TargetVelocity: vector.V{1, 1},
+				Velocity:       vector.V{1, 1},
+				MaxVelocity:    math.Sqrt(2),
+				Heading:        polar.V{1, math.Pi / 4},
+				Radius:         1,
+				Mass:           1,
+				Size:           size.FSmall,
 			})
-			// Add xmax border.
-			db.InsertFeature(feature.O{
-				AABB: *hyperrectangle.New(vector.V{max, min - 1}, vector.V{max + 1, max + 1}),
+
+			ctx, cancel := c.ctx()
+			defer cancel()
+
+			err := collider.TickContext(ctx, 100*time.Millisecond)
+			if !errors.Is(err, c.want) {
+				t.Fatalf("TickContext() = %v, want = %v", err, c.want)
+			}
+
+			want := vector.V{10.1, 10.1}
+			if c.want != nil {
+				want = vector.V{10, 10}
+			}
+			if got := a.Position(); !vector.Within(got, want) {
+				t.Errorf("Position() = %v, want = %v", got, want)
+			}
+		})
+	}
+}
+
+func TestTickContextConsistent(t *testing.T) {
+	const n = 1000
+
+	// transitions is the number of altitude transitions committed in the
+	// current tick.
+	var transitions int
+
+	db := database.New(database.DefaultO)
+	collider := New(db, O{
+		PoolSize:   DefaultO.PoolSize,
+		OnAltitude: func(a agent.RO, k Altitude) { transitions++ },
+	})
+	defer collider.Close()
+
+	var a agent.RO
+	for i := 0; i < n; i++ {
+		a = db.InsertAgent(agent.O{
+			Radius:          R,
+			Mass:            1,
+			Position:        rv(0, 100),
+			TargetPosition:  vector.V{0, 0},
+			TargetVelocity:  rv(-1, 1),
+			Velocity:        rv(-1, 1),
+			MaxVelocity:     60,
+			MaxAcceleration: 10,
+			Heading:         polar.V{1, 0},
+			Size:            size.FSmall,
+		})
+	}
+
+	// Cancel the tick at varying points during velocity generation, and
+	// ensure either all or none of the agents were moved, and the
+	// requested takeoff is either committed or still pending.
+	for _, timeout := range []time.Duration{0, 10 * time.Microsecond, 100 * time.Microsecond, time.Millisecond, time.Second} {
+		t.Run(fmt.Sprintf("Timeout=%v", timeout), func(t *testing.T) {
+			k := collider.altitudes.agent(a)
+			collider.SetAltitude(a.ID(), AltitudeAir)
+			transitions = 0
+
+			before := make(map[id.ID]vector.V, n)
+			for a := range db.ListAgents() {
+				// Position() returns a reference to the
+				// underlying buffer, which is mutated by
+				// the tick.
+				before[a.ID()] = vector.V{a.Position().X(), a.Position().Y()}
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), timeout)
+			defer cancel()
+
+			err := collider.TickContext(ctx, 33*time.Millisecond)
+
+			moved := 0
+			for a := range db.ListAgents() {
+				if !vector.Within(a.Position(), before[a.ID()]) {
+					moved++
+				}
+			}
+			if err != nil && moved != 0 {
+				t.Errorf("TickContext() = %v, but %v agents were moved", err, moved)
+			}
+			if err == nil && moved == 0 {
+				t.Errorf("TickContext() = nil, but no agents were moved")
+			}
+
+			want := 0
+			if err == nil {
+				want, k = 1, AltitudeAir
+			}
+			if transitions != want {
+				t.Errorf("TickContext() = %v, but OnAltitude() was called %v times, want = %v", err, transitions, want)
+			}
+			if got := collider.altitudes.agent(a); got != k {
+				t.Errorf("TickContext() = %v, but altitude = %v, want = %v", err, got, k)
+			}
+		})
+	}
+}
+
+// spawn is a copy of the generate loop which preceded the worker pool, and is
+// kept as a benchmark baseline. Every call spawns a new set of worker
+// goroutines which fan out agents over the database channel, and query and
+// filter each agent directly against the database. spawn only reads from the
+// input database, and does not share any state with the collider.
+func spawn(db *database.DB, poolSize int, d time.Duration) ([]AgentResult, []ProjectileResult, Stats) {
+	var stats Stats
+	var mu sync.Mutex
+
+	ams := make([]AgentResult, 0, 256)
+	pms := make([]ProjectileResult, 0, 256)
+
+	amsch := make(chan AgentResult, 256)
+	pmsch := make(chan ProjectileResult, 256)
+
+	go func(amsch chan<- AgentResult, pmsch chan<- ProjectileResult) {
+		var wg sync.WaitGroup
+
+		wg.Add(poolSize)
+		go func(ch chan<- ProjectileResult) {
+			defer wg.Done()
+
+			var s Stats
+			for p := range db.ListProjectiles() {
+				s.Projectiles++
+				pmsch <- ProjectileResult{
+					Projectile: p,
+					Velocity:   p.TargetVelocity(),
+					Heading:    polar.Polar(vector.Unit(p.TargetVelocity())),
+				}
+			}
+			close(ch)
+
+			mu.Lock()
+			stats.merge(s)
+			mu.Unlock()
+		}(pmsch)
+
+		in := db.ListAgents()
+		for i := 0; i < poolSize-1; i++ {
+			go func(out chan<- AgentResult) {
+				defer wg.Done()
+
+				var s Stats
+				for a := range in {
+					v := vector.M{0, 0}
+					v.Copy(a.TargetVelocity())
+
+					aabb := a.AABB()
+					ns := db.QueryAgents(aabb, func(b agent.RO) bool {
+						return filters.AgentIsCollidingNotSquishable(a, b)
+					})
+					fs := db.QueryFeatures(aabb, func(f feature.RO) bool {
+						return filters.AgentIsCollidingWithFeature(a, f)
+					})
+
+					s.Agents++
+					s.NeighborQueries++
+					s.FeatureQueries++
+					s.Neighbors += len(ns)
+					s.Features += len(fs)
+					if len(ns) > s.MaxNeighbors {
+						s.MaxNeighbors = len(ns)
+					}
+
+					sort.Slice(ns, func(i, j int) bool { return ns[i].ID() < ns[j].ID() })
+					sort.Slice(fs, func(i, j int) bool { return fs[i].ID() < fs[j].ID() })
+
+					for _, f := range fs {
+						kinematics.SetFeatureCollisionVelocity(a, f, v)
+					}
+					for _, n := range ns {
+						kinematics.SetCollisionVelocity(a, n, v)
+					}
+
+					kinematics.ClampVelocity(a, v)
+					kinematics.ClampAcceleration(a, v, d)
+
+					h := polar.M{0, 0}
+					h.Copy(a.Heading())
+					kinematics.ClampHeading(a, d, v, h)
+
+					moving := !vector.Within(v.V(), vector.V{0, 0})
+					for _, f := range fs {
+						kinematics.ClampFeatureCollisionVelocity(a, f, v)
+					}
+					for _, n := range ns {
+						kinematics.ClampCollisionVelocity(a, n, v)
+					}
+					if moving && vector.Within(v.V(), vector.V{0, 0}) {
+						s.Clamped++
+					}
+
+					out <- AgentResult{
+						Agent:    a,
+						Velocity: v.V(),
+						Heading:  h.V(),
+					}
+				}
+
+				mu.Lock()
+				stats.merge(s)
+				mu.Unlock()
+			}(amsch)
+		}
+
+		wg.Wait()
+		close(amsch)
+	}(amsch, pmsch)
+
+	for r := range amsch {
+		ams = append(ams, r)
+	}
+	for r := range pmsch {
+		pms = append(pms, r)
+	}
+
+	return ams, pms, stats
+}
+
+func BenchmarkGenerate(b *testing.B) {
+	type config struct {
+		name     string
+		n        int
+		coverage float64
+	}
+
+	configs := []config{}
+	for _, n := range []int{1e3, 1e4, 1e5} {
+		configs = append(configs, config{
+			name:     fmt.Sprintf("N=%v/ρ=%v", n, 0.1),
+			n:        n,
+			coverage: 0.1,
+		})
+	}
+
+	for _, c := range configs {
+		area := float64(c.n) * math.Pi * R * R / c.coverage
+		max := math.Sqrt(area)
+
+		db := database.New(database.DefaultO)
+		collider := New(db, DefaultO)
+		for i := 0; i < c.n; i++ {
+			db.InsertAgent(agent.O{
+				Radius:             R,
+				Mass:               1,
+				Position:           rv(0, max),
+				TargetPosition:     vector.V{0, 0},
+				TargetVelocity:     rv(-1, 1),
+				Velocity:           rv(-1, 1),
+				MaxVelocity:        60,
+				MaxAcceleration:    10,
+				MaxAngularVelocity: math.Pi / 4,
+				Heading:            polar.V{1, 0},
+				Size:               size.FSmall,
 			})
-			// Add ymin border.
-			db.InsertFeature(feature.O{
-				AABB: *hyperrectangle.New(vector.V{min, min - 1}, vector.V{max, min}),
+		}
+
+		b.Run(fmt.Sprintf("%v/Pool", c.name), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				collider.generate(context.Background(), 33*time.Millisecond, nil)
+			}
+		})
+		b.Run(fmt.Sprintf("%v/Spawn", c.name), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				spawn(db, DefaultO.PoolSize, 33*time.Millisecond)
+			}
+		})
+
+		collider.Close()
+	}
+}
+
+// commit is the legacy serial commit phase, which is used as a baseline for
+// the batched commit phase.
+func commit(c *C, ams []AgentResult, pms []ProjectileResult) {
+	db := (*database.DB)(c.store.(*dbstore))
+	for _, r := range ams {
+		db.SetAgentPosition(r.Agent.ID(), r.Position)
+		db.SetAgentHeading(r.Agent.ID(), r.Heading)
+		db.SetAgentVelocity(r.Agent.ID(), r.Velocity)
+	}
+	for _, r := range pms {
+		db.SetProjectilePosition(r.Projectile.ID(), r.Position)
+		db.SetProjectileHeading(r.Projectile.ID(), r.Heading)
+		db.SetProjectileVelocity(r.Projectile.ID(), r.Velocity)
+	}
+}
+
+func BenchmarkApply(b *testing.B) {
+	type config struct {
+		name string
+		n    int
+		// idle is the fraction of agents which are stationary.
+		idle float64
+	}
+
+	configs := []config{}
+	for _, n := range []int{1e3, 1e4} {
+		for _, idle := range []float64{0, 0.5, 0.9} {
+			configs = append(configs, config{
+				name: fmt.Sprintf("N=%v/Idle=%v", n, idle),
+				n:    n,
+				idle: idle,
 			})
-			// Add ymax border.
-			db.InsertFeature(feature.O{
-				AABB: *hyperrectangle.New(vector.V{min, max}, vector.V{max, max + 1}),
+		}
+	}
+
+	for _, c := range configs {
+		area := float64(c.n) * math.Pi * R * R / 0.01
+		max := math.Sqrt(area)
+
+		db := database.New(database.DefaultO)
+		collider := New(db, DefaultO)
+		for i := 0; i < c.n; i++ {
+			v := rv(-1, 1)
+			if float64(i) < c.idle*float64(c.n) {
+				v = vector.V{0, 0}
+			}
+			db.InsertAgent(agent.O{
+				Radius:             R,
+				Mass:               1,
+				Position:           rv(0, max),
+				TargetPosition:     vector.V{0, 0},
+				TargetVelocity:     v,
+				Velocity:           v,
+				MaxVelocity:        60,
+				MaxAcceleration:    10,
+				MaxAngularVelocity: math.Pi / 4,
+				Heading:            polar.V{1, 0},
+				Size:               size.FSmall,
 			})
+		}
+
+		ams, pms, _ := collider.generate(context.Background(), 33*time.Millisecond, nil)
 
-			b.StartTimer()
+		b.Run(fmt.Sprintf("%v/Batched", c.name), func(b *testing.B) {
 			for i := 0; i < b.N; i++ {
-				collider.Tick(33 * time.Millisecond)
+				collider.apply(ams, pms)
 			}
 		})
+		b.Run(fmt.Sprintf("%v/Serial", c.name), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				commit(collider, ams, pms)
+			}
+		})
+
+		collider.Close()
+	}
+}
+
+func TestTickSchedule(t *testing.T) {
+	var got Stats
+
+	db := database.New(database.DefaultO)
+	collider := New(db, O{
+		PoolSize: DefaultO.PoolSize,
+		OnStats:  func(s Stats) { got = s },
+	})
+	defer collider.Close()
+
+	var xs []id.ID
+	for i := 0; i < 4; i++ {
+		xs = append(xs, db.InsertAgent(agent.O{
+			Position:        vector.V{10 * float64(i), 0},
+			TargetPosition:  vector.V{0, 0},
+			TargetVelocity:  vector.V{1, 0},
+			Velocity:        vector.V{1, 0},
+			MaxVelocity:     1,
+			MaxAcceleration: 1,
+			Heading:         polar.V{1, 0},
+			Radius:          1,
+			Mass:            1,
+			Size:            size.FSmall,
+		}).ID())
+	}
+
+	// Even agents are advanced by a second, and odd agents are deferred.
+	s := func(a agent.RO) (time.Duration, bool) {
+		return time.Second, a.ID()%2 == xs[0]%2
+	}
+	if err := collider.TickSchedule(context.Background(), 100*time.Millisecond, s); err != nil {
+		t.Fatalf("TickSchedule() = %v, want = nil", err)
+	}
+
+	for i, x := range xs {
+		want := vector.V{10 * float64(i), 0}
+		if i%2 == 0 {
+			want = vector.V{10*float64(i) + 1, 0}
+		}
+		if got := db.GetAgentOrDie(x).Position(); !vector.Within(got, want) {
+			t.Errorf("Position() = %v, want = %v", got, want)
+		}
+	}
+	if got.Agents != 2 || got.Deferred != 2 {
+		t.Errorf("Stats() = %+v, want Agents = 2, Deferred = 2", got)
 	}
 }
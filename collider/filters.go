@@ -0,0 +1,143 @@
+package collider
+
+import (
+	"math"
+
+	"github.com/downflux/go-collider/kinematics"
+	"github.com/downflux/go-database/agent"
+	"github.com/downflux/go-database/feature"
+	"github.com/downflux/go-database/filters"
+	"github.com/downflux/go-geometry/2d/hyperrectangle"
+)
+
+// isColliding is equivalent to filters.AgentIsCollidingNotSquishable, but
+// compares the input altitudes of the two agents instead of their terrain
+// flags, and does not allocate.
+func isColliding(a agent.RO, h Altitude, b agent.RO, k Altitude) bool {
+	if a.ID() == b.ID() || h&k == 0 {
+		return false
+	}
+	if isSquishable(a, b) {
+		return false
+	}
+	return kinematics.IsColliding(a, b)
+}
+
+// isSquishable is equivalent to filters.AgentIsSquishable for agents which
+// share an altitude.
+func isSquishable(a agent.RO, b agent.RO) bool {
+	return !filters.AgentIsTeammate(a, b) && a.Size() < b.Size()
+}
+
+// isCollidingWithFeature is equivalent to filters.AgentIsCollidingWithFeature,
+// but compares the input altitudes of the agent and feature instead of their
+// terrain flags, and does not allocate. The input AABB must be the AABB of the
+// agent.
+//
+// N.B.: filters.AgentIsCollidingWithFeature checks the agent circle against the
+// (infinite) lines which extend the feature edges, which reduces to an overlap
+// check between the AABBs of the agent and the feature.
+func isCollidingWithFeature(h Altitude, aabb hyperrectangle.R, f feature.RO, k Altitude) bool {
+	if h&k == 0 {
+		return false
+	}
+	return !hyperrectangle.Disjoint(aabb, f.AABB())
+}
+
+// Layers is the collision layer assignment of an agent or feature. Two bodies
+// collide only if each body occupies a layer which the other body collides
+// with.
+type Layers struct {
+	// Category is the bitmask of layers which the body occupies.
+	Category uint64
+
+	// Mask is the bitmask of layers which the body collides with.
+	Mask uint64
+}
+
+var (
+	// DefaultLayers is the layer assignment of bodies for which no layers
+	// were specified, and collides with all layers.
+	DefaultLayers = Layers{Category: 1, Mask: math.MaxUint64}
+)
+
+// Collides checks if bodies with the two layer assignments may collide.
+func (l Layers) Collides(m Layers) bool {
+	return l.Category&m.Mask != 0 && m.Category&l.Mask != 0
+}
+
+// filter is the set of user-supplied collision filters, which are applied in
+// addition to the built-in filters. See O for more information.
+type filter struct {
+	agentLayers   func(a agent.RO) Layers
+	featureLayers func(f feature.RO) Layers
+	agent         func(a agent.RO, b agent.RO) bool
+	feature       func(a agent.RO, f feature.RO) bool
+
+	// joints excludes agents which are connected by a joint.
+	joints *joints
+
+	// altitudes is the set of agent altitudes of the collider.
+	altitudes *altitudes
+
+	// motion is the user-specified feature motion hook, and sensor is the
+	// user-specified sensor hook.
+	motion func(f feature.RO) Motion
+	sensor func(f feature.RO) bool
+}
+
+// layers returns the layer assignment of the input agent.
+func (f filter) layers(a agent.RO) Layers {
+	if f.agentLayers == nil {
+		return DefaultLayers
+	}
+	return f.agentLayers(a)
+}
+
+// isColliding checks if the input agent a, with the precomputed layer
+// assignment l and altitude h, collides with the agent b.
+func (f filter) isColliding(a agent.RO, l Layers, h Altitude, b agent.RO) bool {
+	if !isColliding(a, h, b, f.altitudes.agent(b)) || f.joints.connected(a.ID(), b.ID()) {
+		return false
+	}
+	if f.agentLayers != nil && !l.Collides(f.agentLayers(b)) {
+		return false
+	}
+	return f.agent == nil || f.agent(a, b)
+}
+
+// isCollidingWithFeature checks if the input agent a, with the precomputed
+// AABB, layer assignment l, and altitude h, collides with the feature g.
+func (f filter) isCollidingWithFeature(a agent.RO, aabb hyperrectangle.R, l Layers, h Altitude, g feature.RO) bool {
+	if !isCollidingWithFeature(h, aabb, g, f.altitudes.featureAltitude(g)) {
+		return false
+	}
+	if !f.isSolid(g) {
+		return false
+	}
+	if f.featureLayers != nil && !l.Collides(f.featureLayers(g)) {
+		return false
+	}
+	return f.feature == nil || f.feature(a, g)
+}
+
+// isCarrying checks if the input agent a, with the precomputed altitude h,
+// stands on the platform g.
+func (f filter) isCarrying(a agent.RO, h Altitude, g feature.RO) bool {
+	return isCarrying(a, h, g, f.altitudes.featureAltitude(g), motion(f.motion, g))
+}
+
+// isSensing checks if the input agent, with the precomputed AABB and altitude h,
+// may be inside the sensor g.
+func (f filter) isSensing(aabb hyperrectangle.R, h Altitude, g feature.RO) bool {
+	return f.sensor != nil && f.sensor(g) && isSensing(h, aabb, g, f.altitudes.featureAltitude(g))
+}
+
+// isSolid checks if the input feature may block agents, i.e. if the feature is
+// neither a platform nor a sensor.
+func (f filter) isSolid(g feature.RO) bool {
+	if f.motion != nil && motion(f.motion, g).Carry {
+		return false
+	}
+	return f.sensor == nil || !f.sensor(g)
+}
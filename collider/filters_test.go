@@ -0,0 +1,140 @@
+package collider
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/downflux/go-database/agent"
+	"github.com/downflux/go-database/database"
+	"github.com/downflux/go-database/feature"
+	"github.com/downflux/go-database/flags/team"
+	"github.com/downflux/go-geometry/2d/hyperrectangle"
+	"github.com/downflux/go-geometry/2d/vector"
+)
+
+func TestLayersCollides(t *testing.T) {
+	const (
+		ground = 1 << iota
+		air
+	)
+
+	type config struct {
+		name string
+		l    Layers
+		m    Layers
+		want bool
+	}
+
+	configs := []config{
+		{name: "Default", l: DefaultLayers, m: DefaultLayers, want: true},
+		{name: "Disjoint", l: Layers{Category: ground, Mask: ground}, m: Layers{Category: air, Mask: air}, want: false},
+		{name: "OneWay", l: Layers{Category: air, Mask: air}, m: Layers{Category: ground, Mask: math.MaxUint64}, want: false},
+		{name: "Mutual", l: Layers{Category: air, Mask: ground}, m: Layers{Category: ground, Mask: air}, want: true},
+	}
+
+	for _, c := range configs {
+		t.Run(c.name, func(t *testing.T) {
+			if got := c.l.Collides(c.m); got != c.want {
+				t.Errorf("Collides() = %v, want = %v", got, c.want)
+			}
+			if got := c.m.Collides(c.l); got != c.want {
+				t.Errorf("Collides() = %v, want = %v", got, c.want)
+			}
+		})
+	}
+}
+
+// TestFilter verifies an agent moving into a touching neighbor or feature is
+// only blocked if the collision is not filtered out.
+func TestFilter(t *testing.T) {
+	const (
+		ground = 1 << iota
+		air
+	)
+
+	type config struct {
+		name string
+		o    O
+		// f indicates the obstacle is a feature instead of an agent.
+		f    bool
+		want vector.V
+	}
+
+	blocked := vector.V{0, 0}
+	free := vector.V{0.2, 0}
+
+	configs := []config{
+		{name: "Agent/Default", o: DefaultO, want: blocked},
+		{
+			name: "Agent/Layers",
+			o: O{
+				PoolSize: DefaultO.PoolSize,
+				AgentLayers: func(a agent.RO) Layers {
+					if a.Team() == team.FNeutral {
+						return Layers{Category: air, Mask: air}
+					}
+					return Layers{Category: ground, Mask: ground}
+				},
+			},
+			want: free,
+		},
+		{
+			name: "Agent/FilterAgent",
+			o: O{
+				PoolSize:    DefaultO.PoolSize,
+				FilterAgent: func(a agent.RO, b agent.RO) bool { return a.Team() == b.Team() },
+			},
+			want: free,
+		},
+		{name: "Feature/Default", o: DefaultO, f: true, want: blocked},
+		{
+			name: "Feature/Layers",
+			o: O{
+				PoolSize:      DefaultO.PoolSize,
+				AgentLayers:   func(a agent.RO) Layers { return Layers{Category: air, Mask: air} },
+				FeatureLayers: func(f feature.RO) Layers { return Layers{Category: ground, Mask: math.MaxUint64} },
+			},
+			f:    true,
+			want: free,
+		},
+		{
+			name: "Feature/FilterFeature",
+			o: O{
+				PoolSize:      DefaultO.PoolSize,
+				FilterFeature: func(a agent.RO, f feature.RO) bool { return false },
+			},
+			f:    true,
+			want: free,
+		},
+	}
+
+	for _, c := range configs {
+		t.Run(c.name, func(t *testing.T) {
+			db := database.New(database.DefaultO)
+			collider := New(db, c.o)
+			defer collider.Close()
+
+			o := idle(vector.V{0, 0})
+			o.TargetVelocity = vector.V{10, 0}
+			o.Velocity = vector.V{10, 0}
+			x := db.InsertAgent(o).ID()
+
+			if c.f {
+				db.InsertFeature(feature.O{
+					AABB: *hyperrectangle.New(vector.V{R, -1}, vector.V{2, 1}),
+				})
+			} else {
+				o := idle(vector.V{2 * R, 0})
+				o.Team = team.FNeutral + 1
+				db.InsertAgent(o)
+			}
+
+			collider.Tick(20 * time.Millisecond)
+
+			if got := db.GetAgentOrDie(x).Position(); !vector.Within(got, c.want) {
+				t.Errorf("Position() = %v, want = %v", got, c.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,170 @@
+package collider
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/downflux/go-database/agent"
+	"github.com/downflux/go-geometry/2d/hyperrectangle"
+)
+
+var (
+	_ Broadphase = &Grid{}
+)
+
+// Grid is a uniform-grid spatial hash broadphase, and is intended for
+// simulations in which agents have similar radii.
+//
+// Each agent is hashed into the cell which contains its center, and queries
+// are expanded by the largest agent radius in the tick in order to find agents
+// which overlap the query from an adjacent cell. The cell width should
+// therefore be on the order of the agent diameter.
+//
+// The grid is rebuilt every tick with a counting sort over a fixed-size hash
+// table, and does not allocate once its buffers have grown to fit the
+// simulation.
+type Grid struct {
+	cell float64
+
+	// r is the largest agent radius seen in the last update.
+	r float64
+
+	// mask is the hash table size minus one. The table size is always a
+	// power of two.
+	mask int
+
+	// starts[h] is the offset into entries of the first agent in bucket h.
+	// The last element is a sentinel marking the end of the final bucket.
+	starts  []int
+	entries []entry
+
+	// scratch and keys are the unsorted entries and their bucket indices.
+	scratch []entry
+	keys    []int
+}
+
+type entry struct {
+	a agent.RO
+
+	// x, y, and r are the agent position and radius, and are cached here
+	// to avoid an indirect call during queries.
+	x float64
+	y float64
+	r float64
+
+	// i and j are the coordinates of the agent cell, and are used to
+	// disambiguate between cells which hash into the same bucket.
+	i int
+	j int
+}
+
+// NewGrid constructs a grid broadphase with the input cell width.
+func NewGrid(cell float64) *Grid {
+	if cell <= 0 {
+		panic(fmt.Sprintf("cell width specified %v is not positive", cell))
+	}
+	return &Grid{cell: cell}
+}
+
+func (g *Grid) index(x float64) int { return int(math.Floor(x / g.cell)) }
+
+func (g *Grid) key(i int, j int) int {
+	h := uint64(i)*0x9e3779b97f4a7c15 ^ uint64(j)*0xc2b2ae3d27d4eb4f
+	return int((h ^ h>>32) & uint64(g.mask))
+}
+
+// Update rebuilds the grid from the current agent positions.
+func (g *Grid) Update(agents []agent.RO) {
+	n := len(agents)
+
+	b := 1
+	for b < 2*n {
+		b <<= 1
+	}
+	g.mask = b - 1
+
+	if cap(g.starts) < b+1 {
+		g.starts = make([]int, b+1)
+	}
+	g.starts = g.starts[:b+1]
+	for h := range g.starts {
+		g.starts[h] = 0
+	}
+	if cap(g.entries) < n {
+		g.entries = make([]entry, n)
+		g.scratch = make([]entry, n)
+		g.keys = make([]int, n)
+	}
+	g.entries = g.entries[:n]
+	g.scratch = g.scratch[:n]
+	g.keys = g.keys[:n]
+
+	g.r = 0
+	for k, a := range agents {
+		p := a.Position()
+		i, j := g.index(p.X()), g.index(p.Y())
+
+		h := g.key(i, j)
+		g.keys[k] = h
+		g.starts[h]++
+
+		g.scratch[k] = entry{a: a, x: p.X(), y: p.Y(), r: a.Radius(), i: i, j: j}
+		if r := a.Radius(); r > g.r {
+			g.r = r
+		}
+	}
+
+	// Convert the bucket counts into end offsets, and then fill each
+	// bucket from the back, which leaves starts[h] as the start offset of
+	// bucket h.
+	for h := 1; h < b; h++ {
+		g.starts[h] += g.starts[h-1]
+	}
+	g.starts[b] = n
+
+	for k := n - 1; k >= 0; k-- {
+		h := g.keys[k]
+		g.starts[h]--
+		g.entries[g.starts[h]] = g.scratch[k]
+	}
+}
+
+// Query appends into the input buffer all agents whose AABBs overlap the query
+// rectangle and which pass the input filter.
+func (g *Grid) Query(q hyperrectangle.R, filter func(a agent.RO) bool, buf []agent.RO) []agent.RO {
+	lo, hi := q.Min(), q.Max()
+
+	imin, jmin := g.index(lo.X()-g.r), g.index(lo.Y()-g.r)
+	imax, jmax := g.index(hi.X()+g.r), g.index(hi.Y()+g.r)
+
+	// Large queries are cheaper as a linear scan over all agents.
+	if float64(imax-imin+1)*float64(jmax-jmin+1) > float64(len(g.entries)) {
+		for k := range g.entries {
+			if e := &g.entries[k]; e.overlaps(q) && filter(e.a) {
+				buf = append(buf, e.a)
+			}
+		}
+		return buf
+	}
+
+	for i := imin; i <= imax; i++ {
+		for j := jmin; j <= jmax; j++ {
+			h := g.key(i, j)
+			for k := g.starts[h]; k < g.starts[h+1]; k++ {
+				e := &g.entries[k]
+				if e.i != i || e.j != j {
+					continue
+				}
+				if e.overlaps(q) && filter(e.a) {
+					buf = append(buf, e.a)
+				}
+			}
+		}
+	}
+	return buf
+}
+
+func (e *entry) overlaps(q hyperrectangle.R) bool {
+	lo, hi := q.Min(), q.Max()
+	return e.x-e.r <= hi.X() && e.x+e.r >= lo.X() && e.y-e.r <= hi.Y() && e.y+e.r >= lo.Y()
+}
@@ -0,0 +1,127 @@
+package collider
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/downflux/go-bvh/id"
+	"github.com/downflux/go-database/agent"
+	"github.com/downflux/go-database/database"
+	"github.com/downflux/go-database/flags/size"
+	"github.com/downflux/go-geometry/2d/hyperrectangle"
+	"github.com/downflux/go-geometry/2d/vector"
+	"github.com/downflux/go-geometry/2d/vector/polar"
+)
+
+func TestGridQuery(t *testing.T) {
+	type config struct {
+		name string
+		cell float64
+		n    int
+	}
+
+	configs := []config{
+		{name: "Empty", cell: 1, n: 0},
+		{name: "Single", cell: 1, n: 1},
+		{name: "Diameter", cell: 2 * R, n: 500},
+		// Small cells force agents to overlap many cells.
+		{name: "SmallCell", cell: R / 4, n: 500},
+		// Large cells force many agents into the same cell.
+		{name: "LargeCell", cell: 100, n: 500},
+	}
+
+	for _, c := range configs {
+		t.Run(c.name, func(t *testing.T) {
+			var agents []agent.RO
+			for i := 0; i < c.n; i++ {
+				agents = append(agents, &body{
+					id: id.ID(i),
+					p:  vector.M(rv(-20, 20)),
+					r:  rn(0.1, 1),
+				})
+			}
+
+			g := NewGrid(c.cell)
+			g.Update(agents)
+
+			all := func(agent.RO) bool { return true }
+			for i := 0; i < 100; i++ {
+				p := rv(-25, 25)
+				d := rv(0, 5)
+				q := *hyperrectangle.New(p, vector.V{p.X() + d.X(), p.Y() + d.Y()})
+
+				var want []id.ID
+				for _, a := range agents {
+					if !hyperrectangle.Disjoint(q, a.(*body).AABB()) {
+						want = append(want, a.ID())
+					}
+				}
+
+				var got []id.ID
+				for _, a := range g.Query(q, all, nil) {
+					got = append(got, a.ID())
+				}
+
+				sort.Slice(got, func(i, j int) bool { return got[i] < got[j] })
+				if fmt.Sprint(got) != fmt.Sprint(want) {
+					t.Fatalf("Query() = %v, want = %v", got, want)
+				}
+			}
+		})
+	}
+}
+
+// TestGridTick verifies the grid broadphase generates the same ticks as the
+// default database broadphase.
+func TestGridTick(t *testing.T) {
+	const n = 300
+
+	var os []agent.O
+	max := math.Sqrt(float64(n) * math.Pi * R * R / 0.5)
+	for i := 0; i < n; i++ {
+		os = append(os, agent.O{
+			Radius:             R,
+			Mass:               1,
+			Position:           rv(0, max),
+			TargetPosition:     vector.V{0, 0},
+			TargetVelocity:     rv(-1, 1),
+			Velocity:           rv(-1, 1),
+			MaxVelocity:        60,
+			MaxAcceleration:    10,
+			MaxAngularVelocity: math.Pi / 4,
+			Heading:            polar.V{1, 0},
+			Size:               size.FSmall,
+		})
+	}
+
+	var dbs []*database.DB
+	for _, bp := range []Broadphase{nil, NewGrid(2 * R)} {
+		db := database.New(database.DefaultO)
+		for _, o := range os {
+			db.InsertAgent(o)
+		}
+
+		o := DefaultO
+		o.Broadphase = bp
+		c := New(db, o)
+		for i := 0; i < 20; i++ {
+			c.Tick(20 * time.Millisecond)
+		}
+		c.Close()
+
+		dbs = append(dbs, db)
+	}
+
+	for a := range dbs[0].ListAgents() {
+		b := dbs[1].GetAgentOrDie(a.ID())
+		if !vector.Within(a.Position(), b.Position()) {
+			t.Errorf("Position() = %v, want = %v", b.Position(), a.Position())
+		}
+		if !vector.Within(a.Velocity(), b.Velocity()) {
+			t.Errorf("Velocity() = %v, want = %v", b.Velocity(), a.Velocity())
+		}
+	}
+}
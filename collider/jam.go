@@ -0,0 +1,212 @@
+package collider
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/downflux/go-bvh/id"
+	"github.com/downflux/go-database/agent"
+)
+
+// JamStrategy is the resolution applied to jammed agents by the Unjam pipeline
+// stage.
+type JamStrategy int
+
+const (
+	// JamReport only reports jammed agents, and does not modify their
+	// velocities.
+	JamReport JamStrategy = iota
+
+	// JamSidestep rotates the velocity of a jammed agent to its right, so
+	// that e.g. two agents which are jammed head-on pass one another.
+	JamSidestep
+
+	// JamYield moves a jammed agent out of the path of any jammed neighbor
+	// with a higher priority. Agents are ranked by the collider Priority
+	// hook, and then by ID, where smaller IDs have a higher priority. The
+	// jammed agent with the highest priority keeps its velocity.
+	JamYield
+)
+
+func (s JamStrategy) String() string {
+	switch s {
+	case JamReport:
+		return "report"
+	case JamSidestep:
+		return "sidestep"
+	case JamYield:
+		return "yield"
+	default:
+		return fmt.Sprintf("JamStrategy(%d)", int(s))
+	}
+}
+
+// stall is the jam tracking state of a single agent.
+type stall struct {
+	// ticks is the number of consecutive ticks in which the agent wanted
+	// to move but its generated velocity was zero.
+	ticks int
+
+	// jammed indicates the agent is jammed, and x and y are the position
+	// of the agent when it became jammed.
+	jammed bool
+	x      float64
+	y      float64
+}
+
+// jams tracks the agents which are jammed across ticks.
+//
+// An agent stalls in a tick if it has a non-zero target velocity, but its
+// generated velocity is zero, e.g. because its velocity was clamped to zero by
+// a collision. An agent which stalls for the configured number of consecutive
+// ticks is jammed. A jammed agent stays jammed until it has moved at least its
+// radius away from the position where it became jammed, or until its target
+// velocity is zero, so that the resolution strategy is applied for long enough
+// to break the jam.
+type jams struct {
+	ticks    int
+	strategy JamStrategy
+
+	// stalls maps each stalled or jammed agent to its tracking state.
+	// stalls is only written to serially, and is read concurrently by the
+	// Unjam stage.
+	stalls map[id.ID]stall
+
+	// index is used to remove agents which have been deleted from the
+	// store.
+	index map[id.ID]struct{}
+
+	// jammed is the per-tick buffer of jammed agents, sorted by ID.
+	jammed byID
+}
+
+func newJams(ticks int, strategy JamStrategy) *jams {
+	if ticks < 1 {
+		panic(fmt.Sprintf("JamTicks specified %v is smaller than the minimum value of 1", ticks))
+	}
+	switch strategy {
+	case JamReport, JamSidestep, JamYield:
+	default:
+		panic(fmt.Sprintf("invalid jam strategy %v", strategy))
+	}
+	return &jams{
+		ticks:    ticks,
+		strategy: strategy,
+		stalls:   map[id.ID]stall{},
+		index:    map[id.ID]struct{}{},
+	}
+}
+
+// isJammed checks if the input agent was jammed at the end of the previous
+// tick.
+func (j *jams) isJammed(x id.ID) bool {
+	if j == nil {
+		return false
+	}
+	return j.stalls[x].jammed
+}
+
+// update records the generated results of the current tick, and returns the
+// agents which are jammed at the end of the tick, sorted by ID. update is
+// called serially once the tick has been committed. Skipped agents are not
+// advanced, and their tracking state is unchanged.
+func (j *jams) update(rs []AgentResult) []agent.RO {
+	for x := range j.index {
+		delete(j.index, x)
+	}
+	j.jammed = j.jammed[:0]
+
+	// m is the number of tracked agents which are still in the store.
+	m := 0
+	for _, r := range rs {
+		a := r.Agent
+		j.index[a.ID()] = struct{}{}
+		if r.Skipped {
+			if s, ok := j.stalls[a.ID()]; ok {
+				m++
+				if s.jammed {
+					j.jammed = append(j.jammed, a)
+				}
+			}
+			continue
+		}
+
+		s := j.stalls[a.ID()]
+		t := a.TargetVelocity()
+		if t.X() == 0 && t.Y() == 0 {
+			delete(j.stalls, a.ID())
+			continue
+		}
+
+		p := r.Position
+		if s.jammed && math.Hypot(p.X()-s.x, p.Y()-s.y) >= a.Radius() {
+			s = stall{}
+		}
+		if !s.jammed {
+			if r.Moved {
+				s.ticks = 0
+			} else {
+				s.ticks++
+			}
+			if s.ticks >= j.ticks {
+				s.jammed, s.x, s.y = true, p.X(), p.Y()
+			}
+		}
+
+		if s.ticks == 0 && !s.jammed {
+			delete(j.stalls, a.ID())
+			continue
+		}
+		m++
+		j.stalls[a.ID()] = s
+		if s.jammed {
+			j.jammed = append(j.jammed, a)
+		}
+	}
+
+	// Remove agents which have been deleted from the store.
+	if m < len(j.stalls) {
+		for x := range j.stalls {
+			if _, ok := j.index[x]; !ok {
+				delete(j.stalls, x)
+			}
+		}
+	}
+
+	sort.Sort(&j.jammed)
+	return j.jammed
+}
+
+// unjam applies the resolution strategy to the pipeline velocity of a jammed
+// agent.
+func (j *jams) unjam(s *State) {
+	if j == nil || j.strategy == JamReport || !j.isJammed(s.Agent.ID()) {
+		return
+	}
+	v := s.Velocity
+	switch j.strategy {
+	case JamSidestep:
+		x, y := v.X(), v.Y()
+		v.SetX(y)
+		v.SetY(-x)
+	case JamYield:
+		// Yield to the jammed neighbor with the highest priority.
+		var b agent.RO
+		for _, n := range s.Neighbors {
+			if j.isJammed(n.ID()) && outranks(s.priority, n, s.Agent) && (b == nil || outranks(s.priority, n, b)) {
+				b = n
+			}
+		}
+		if b != nil {
+			yield(s.Agent, b, speed(s.Agent, b), v)
+		}
+	}
+}
+
+// byID sorts agents by ID.
+type byID []agent.RO
+
+func (s *byID) Len() int           { return len(*s) }
+func (s *byID) Swap(i, j int)      { (*s)[i], (*s)[j] = (*s)[j], (*s)[i] }
+func (s *byID) Less(i, j int) bool { return (*s)[i].ID() < (*s)[j].ID() }
@@ -0,0 +1,73 @@
+package collider
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/downflux/go-bvh/id"
+	"github.com/downflux/go-database/agent"
+	"github.com/downflux/go-database/database"
+	"github.com/downflux/go-geometry/2d/vector"
+	"github.com/downflux/go-geometry/2d/vector/polar"
+)
+
+func TestJam(t *testing.T) {
+	type config struct {
+		name     string
+		strategy JamStrategy
+
+		// jammed is the number of ticks after which both agents are
+		// expected to be reported as jammed, and passed indicates the
+		// agents are expected to pass one another.
+		jammed int
+		passed bool
+	}
+
+	configs := []config{
+		{name: "Report", strategy: JamReport, jammed: 5, passed: false},
+		{name: "Sidestep", strategy: JamSidestep, jammed: 5, passed: true},
+		{name: "Yield", strategy: JamYield, jammed: 5, passed: true},
+	}
+
+	for _, c := range configs {
+		t.Run(c.name, func(t *testing.T) {
+			var got []int
+
+			tick := 0
+			db := database.New(database.DefaultO)
+			collider := New(db, O{
+				PoolSize:    DefaultO.PoolSize,
+				JamTicks:    5,
+				JamStrategy: c.strategy,
+				OnJam: func(as []agent.RO) {
+					if len(as) == 2 && got == nil {
+						got = []int{tick}
+					}
+				},
+			})
+			defer collider.Close()
+
+			// The two agents are in contact head-on.
+			oa := idle(vector.V{0, 0})
+			oa.TargetVelocity = vector.V{1, 0}
+			ob := idle(vector.V{2 * R, 0})
+			ob.TargetVelocity = vector.V{-1, 0}
+			ob.Heading = polar.V{1, math.Pi}
+
+			a, b := db.InsertAgent(oa).ID(), db.InsertAgent(ob).ID()
+
+			for tick = 1; tick <= 200; tick++ {
+				collider.Tick(20 * time.Millisecond)
+			}
+
+			if want := []int{c.jammed}; len(got) != 1 || got[0] != want[0] {
+				t.Errorf("OnJam() = %v, want = %v", got, want)
+			}
+			p := func(x id.ID) float64 { return db.GetAgentOrDie(x).Position().X() }
+			if got := p(a) > p(b); got != c.passed {
+				t.Errorf("passed = %v, want = %v", got, c.passed)
+			}
+		})
+	}
+}
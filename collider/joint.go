@@ -0,0 +1,285 @@
+package collider
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/downflux/go-bvh/id"
+	"github.com/downflux/go-database/agent"
+	"github.com/downflux/go-geometry/2d/vector"
+)
+
+type JointType int
+
+const (
+	// JointDistance keeps the two agents at a fixed distance from one
+	// another, e.g. the wagons of a train.
+	JointDistance JointType = iota
+
+	// JointRope keeps the two agents within a maximum distance of one
+	// another, e.g. tethered units.
+	JointRope
+
+	// JointRigid keeps agent B at a fixed offset from agent A, where the
+	// offset rotates with the heading of A, e.g. towed artillery. Only B
+	// is moved by the joint.
+	JointRigid
+)
+
+func (t JointType) String() string {
+	switch t {
+	case JointDistance:
+		return "distance"
+	case JointRope:
+		return "rope"
+	case JointRigid:
+		return "rigid"
+	default:
+		return fmt.Sprintf("JointType(%d)", int(t))
+	}
+}
+
+// Joint is a constraint between two agents.
+//
+// Joints are solved by the Joints pipeline stage, which adjusts the velocity
+// of each end of the joint so that the joint does not drift further during the
+// tick, assuming the other end moves at its target velocity, and so that any
+// existing error is corrected over time. The correction is split between the
+// two ends by mass. As the stage runs before the collision and kinematic stages
+// of the pipeline, the corrected velocity is still subject to collisions and to
+// the MaxVelocity and MaxAcceleration of each end, and the constraint may
+// therefore be satisfied only over several ticks.
+//
+// Agents connected by a joint do not collide with one another, and are never
+// put to sleep.
+type Joint struct {
+	Type JointType
+
+	A id.ID
+	B id.ID
+
+	// Length is the distance between the two agents for distance joints,
+	// and the maximum distance for rope joints.
+	Length float64
+
+	// Offset is the position of B relative to A for rigid joints, in the
+	// frame of A, where the X-axis points along the heading of A.
+	Offset vector.V
+}
+
+const (
+	// relaxation is the time in seconds over which the existing error of a
+	// joint is corrected. Correcting the full error in a single tick
+	// overshoots once the correction is clamped by the acceleration limits
+	// of the agents.
+	relaxation = 0.5
+)
+
+// joints tracks the set of joints of a collider.
+type joints struct {
+	next   id.ID
+	joints map[id.ID]Joint
+
+	// of maps each agent to the joints which it is attached to, in
+	// insertion order.
+	of map[id.ID][]id.ID
+
+	// bodies maps each jointed agent to its state in the current tick.
+	bodies map[id.ID]agent.RO
+}
+
+func newJoints() *joints {
+	return &joints{
+		joints: map[id.ID]Joint{},
+		of:     map[id.ID][]id.ID{},
+		bodies: map[id.ID]agent.RO{},
+	}
+}
+
+func (j *joints) insert(o Joint) id.ID {
+	switch o.Type {
+	case JointDistance, JointRope:
+		if o.Length < 0 {
+			panic(fmt.Sprintf("joint length specified %v is negative", o.Length))
+		}
+	case JointRigid:
+	default:
+		panic(fmt.Sprintf("invalid joint type %v", o.Type))
+	}
+	if o.A == o.B {
+		panic(fmt.Sprintf("cannot join agent %v to itself", o.A))
+	}
+	if o.Type == JointRigid {
+		o.Offset = vector.V{o.Offset.X(), o.Offset.Y()}
+	}
+
+	x := j.next
+	j.next++
+
+	j.joints[x] = o
+	j.of[o.A] = append(j.of[o.A], x)
+	j.of[o.B] = append(j.of[o.B], x)
+	return x
+}
+
+func (j *joints) delete(x id.ID) {
+	o, ok := j.joints[x]
+	if !ok {
+		panic(fmt.Sprintf("cannot find joint %v", x))
+	}
+	delete(j.joints, x)
+	for _, y := range []id.ID{o.A, o.B} {
+		xs := j.of[y]
+		for i := range xs {
+			if xs[i] == x {
+				xs = append(xs[:i], xs[i+1:]...)
+				break
+			}
+		}
+		if len(xs) == 0 {
+			delete(j.of, y)
+		} else {
+			j.of[y] = xs
+		}
+	}
+}
+
+// has checks if the input agent is attached to any joint.
+func (j *joints) has(x id.ID) bool {
+	if j == nil || len(j.of) == 0 {
+		return false
+	}
+	_, ok := j.of[x]
+	return ok
+}
+
+// connected checks if the two input agents are attached to the same joint.
+func (j *joints) connected(a id.ID, b id.ID) bool {
+	if j == nil || len(j.of) == 0 {
+		return false
+	}
+	for _, x := range j.of[a] {
+		if o := j.joints[x]; o.A == b || o.B == b {
+			return true
+		}
+	}
+	return false
+}
+
+// update records the state of all jointed agents in the current tick. update
+// is called serially before velocity generation.
+func (j *joints) update(agents []agent.RO) {
+	for x := range j.bodies {
+		delete(j.bodies, x)
+	}
+	if len(j.of) == 0 {
+		return
+	}
+	for _, a := range agents {
+		if _, ok := j.of[a.ID()]; ok {
+			j.bodies[a.ID()] = a
+		}
+	}
+}
+
+// solve adjusts the pipeline velocity of the current agent towards satisfying
+// all joints attached to the agent. Joints whose other end no longer exists
+// are ignored.
+func (j *joints) solve(s *State) {
+	if j == nil || len(j.of) == 0 {
+		return
+	}
+	a := s.Agent
+	xs, ok := j.of[a.ID()]
+	if !ok {
+		return
+	}
+	t := s.Duration.Seconds()
+	if t <= 0 {
+		return
+	}
+
+	for _, x := range xs {
+		o := j.joints[x]
+
+		y := o.B
+		if a.ID() == o.B {
+			y = o.A
+		}
+		b, ok := j.bodies[y]
+		if !ok {
+			continue
+		}
+
+		// k is the share of the correction taken by the current agent.
+		// Rigid joints attach B to A, and do not affect A.
+		k := 0.5
+		if m := a.Mass() + b.Mass(); m > 0 {
+			k = b.Mass() / m
+		}
+		if o.Type == JointRigid {
+			k = 0
+			if a.ID() == o.B {
+				k = 1
+			}
+		}
+		if k == 0 {
+			continue
+		}
+
+		// p and q are the current positions of the current agent and
+		// the other end of the joint, and p' and q' are their predicted
+		// positions after the tick.
+		p, q := a.Position(), b.Position()
+		w := b.TargetVelocity()
+		px, py := p.X()+s.Velocity.X()*t, p.Y()+s.Velocity.Y()*t
+		qx, qy := q.X()+w.X()*t, q.Y()+w.Y()*t
+
+		// (ex, ey) is the displacement of the current agent which
+		// satisfies the joint after the tick, and (fx, fy) is the
+		// displacement which satisfies the joint now. The correction
+		// removes the predicted drift of the joint during the tick, and
+		// corrects the existing error over the relaxation time.
+		var ex, ey, fx, fy float64
+		switch o.Type {
+		case JointDistance, JointRope:
+			ex, ey = stretch(qx-px, qy-py, o.Length)
+			fx, fy = stretch(q.X()-p.X(), q.Y()-p.Y(), o.Length)
+			if o.Type == JointRope {
+				if ex*(qx-px)+ey*(qy-py) <= 0 {
+					continue
+				}
+				if fx*(q.X()-p.X())+fy*(q.Y()-p.Y()) <= 0 {
+					fx, fy = 0, 0
+				}
+			}
+		case JointRigid:
+			// The anchor of B rotates with the heading of A.
+			sin, cos := math.Sincos(b.Heading().Theta())
+			ox := o.Offset.X()*cos - o.Offset.Y()*sin
+			oy := o.Offset.X()*sin + o.Offset.Y()*cos
+
+			ex, ey = qx+ox-px, qy+oy-py
+			fx, fy = q.X()+ox-p.X(), q.Y()+oy-p.Y()
+		}
+
+		ex, ey = k*((ex-fx)/t+fx/relaxation), k*((ey-fy)/t+fy/relaxation)
+		if o.Type == JointRope && ex*(qx-px)+ey*(qy-py) < 0 {
+			continue
+		}
+
+		s.Velocity.SetX(s.Velocity.X() + ex)
+		s.Velocity.SetY(s.Velocity.Y() + ey)
+	}
+}
+
+// stretch returns the displacement along the input direction (dx, dy) which
+// brings its length to l.
+func stretch(dx float64, dy float64, l float64) (float64, float64) {
+	r := math.Sqrt(dx*dx + dy*dy)
+	if r == 0 {
+		return 0, 0
+	}
+	e := r - l
+	return e * dx / r, e * dy / r
+}
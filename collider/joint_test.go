@@ -0,0 +1,193 @@
+package collider
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/downflux/go-database/agent"
+	"github.com/downflux/go-database/database"
+	"github.com/downflux/go-geometry/2d/vector"
+	"github.com/downflux/go-geometry/2d/vector/polar"
+)
+
+func TestJoint(t *testing.T) {
+	type config struct {
+		name  string
+		a     agent.O
+		b     agent.O
+		joint Joint
+		sleep bool
+
+		// want is the expected position of B relative to A at the end
+		// of the simulation.
+		want vector.V
+	}
+
+	// moving returns an agent which moves along the X-axis.
+	moving := func(p vector.V) agent.O {
+		o := idle(p)
+		o.TargetVelocity = vector.V{1, 0}
+		o.Velocity = vector.V{1, 0}
+		return o
+	}
+
+	// Agents which are pushed or pulled backwards by a joint need to turn
+	// around first. Allow idle agents to turn freely in order to keep the
+	// agents on the X-axis.
+	idle := func(p vector.V) agent.O {
+		o := idle(p)
+		o.MaxAngularVelocity = 100 * math.Pi
+		return o
+	}
+
+	configs := []config{
+		{
+			name:  "Distance/Stretched",
+			a:     idle(vector.V{0, 0}),
+			b:     idle(vector.V{3, 0}),
+			joint: Joint{Type: JointDistance, Length: 1},
+			want:  vector.V{1, 0},
+		},
+		{
+			name:  "Distance/Compressed",
+			a:     idle(vector.V{0, 0}),
+			b:     idle(vector.V{0.5, 0}),
+			joint: Joint{Type: JointDistance, Length: 1},
+			want:  vector.V{1, 0},
+		},
+		// Jointed agents do not collide, and may therefore be pulled
+		// into one another.
+		{
+			name:  "Distance/Overlapping",
+			a:     idle(vector.V{0, 0}),
+			b:     idle(vector.V{2, 0}),
+			joint: Joint{Type: JointDistance, Length: 0.5},
+			want:  vector.V{0.5, 0},
+		},
+		{
+			name:  "Rope/Slack",
+			a:     idle(vector.V{0, 0}),
+			b:     idle(vector.V{1.5, 0}),
+			joint: Joint{Type: JointRope, Length: 2},
+			want:  vector.V{1.5, 0},
+		},
+		{
+			name:  "Rope/Tow",
+			a:     moving(vector.V{0, 0}),
+			b:     idle(vector.V{-3, 0}),
+			joint: Joint{Type: JointRope, Length: 2},
+			want:  vector.V{-2, 0},
+		},
+		// Idle agents which are towed must not fall asleep.
+		{
+			name:  "Rope/Tow/Sleep",
+			a:     moving(vector.V{0, 0}),
+			b:     idle(vector.V{-3, 0}),
+			joint: Joint{Type: JointRope, Length: 2},
+			sleep: true,
+			want:  vector.V{-2, 0},
+		},
+		{
+			name:  "Rigid",
+			a:     moving(vector.V{0, 0}),
+			b:     idle(vector.V{-1, 1}),
+			joint: Joint{Type: JointRigid, Offset: vector.V{-1.5, 0}},
+			want:  vector.V{-1.5, 0},
+		},
+		// The offset of a rigid joint is in the frame of A.
+		{
+			name: "Rigid/Heading",
+			a: func() agent.O {
+				o := moving(vector.V{0, 0})
+				o.TargetVelocity = vector.V{0, 1}
+				o.Velocity = vector.V{0, 1}
+				o.Heading = polar.V{1, math.Pi / 2}
+				return o
+			}(),
+			b:     idle(vector.V{0, 0.5}),
+			joint: Joint{Type: JointRigid, Offset: vector.V{-1.5, 0}},
+			want:  vector.V{0, -1.5},
+		},
+	}
+
+	for _, c := range configs {
+		t.Run(c.name, func(t *testing.T) {
+			db := database.New(database.DefaultO)
+			collider := New(db, O{
+				PoolSize: DefaultO.PoolSize,
+				Sleep:    c.sleep,
+			})
+			defer collider.Close()
+
+			j := c.joint
+			j.A = db.InsertAgent(c.a).ID()
+			j.B = db.InsertAgent(c.b).ID()
+			collider.InsertJoint(j)
+
+			for i := 0; i < 250; i++ {
+				collider.Tick(20 * time.Millisecond)
+			}
+
+			a, b := db.GetAgentOrDie(j.A), db.GetAgentOrDie(j.B)
+			if got := vector.Sub(b.Position(), a.Position()); vector.Magnitude(vector.Sub(got, c.want)) > 0.05 {
+				t.Errorf("Position() = %v, want = %v", got, c.want)
+			}
+		})
+	}
+}
+
+// TestJointMaxVelocity verifies the towed end of a joint does not exceed its
+// max velocity, even if the joint is stretched as a result.
+func TestJointMaxVelocity(t *testing.T) {
+	db := database.New(database.DefaultO)
+	collider := New(db, DefaultO)
+	defer collider.Close()
+
+	a := idle(vector.V{0, 0})
+	a.TargetVelocity = vector.V{2, 0}
+	a.Velocity = vector.V{2, 0}
+	a.Mass = 1e3
+
+	b := idle(vector.V{-1, 0})
+	b.MaxVelocity = 0.5
+
+	x := db.InsertAgent(a).ID()
+	y := db.InsertAgent(b).ID()
+	collider.InsertJoint(Joint{Type: JointDistance, A: x, B: y, Length: 1})
+
+	for i := 0; i < 50; i++ {
+		collider.Tick(20 * time.Millisecond)
+		if got := vector.Magnitude(db.GetAgentOrDie(y).Velocity()); got > b.MaxVelocity+1e-9 {
+			t.Fatalf("Magnitude() = %v, want <= %v", got, b.MaxVelocity)
+		}
+	}
+	if got := db.GetAgentOrDie(y).Position().X(); got >= 0 || math.Abs(got+1) < 0.1 {
+		t.Errorf("X() = %v, want in (-1, 0)", got)
+	}
+}
+
+func TestDeleteJoint(t *testing.T) {
+	db := database.New(database.DefaultO)
+	collider := New(db, DefaultO)
+	defer collider.Close()
+
+	x := db.InsertAgent(idle(vector.V{0, 0})).ID()
+	y := db.InsertAgent(idle(vector.V{0.5, 0})).ID()
+	z := db.InsertAgent(idle(vector.V{1, 0})).ID()
+
+	j := collider.InsertJoint(Joint{Type: JointRope, A: x, B: y, Length: 1})
+	collider.InsertJoint(Joint{Type: JointRope, A: y, B: z, Length: 1})
+
+	collider.DeleteJoint(j)
+
+	if got := collider.joints.connected(x, y); got {
+		t.Errorf("connected() = %v, want = %v", got, false)
+	}
+	if got := collider.joints.has(x); got {
+		t.Errorf("has() = %v, want = %v", got, false)
+	}
+	if got := collider.joints.connected(z, y); !got {
+		t.Errorf("connected() = %v, want = %v", got, true)
+	}
+}
@@ -0,0 +1,113 @@
+package collider
+
+import (
+	"github.com/downflux/go-database/agent"
+	"github.com/downflux/go-database/feature"
+	"github.com/downflux/go-geometry/2d/hyperrectangle"
+	"github.com/downflux/go-geometry/2d/vector"
+)
+
+// Motion is the motion of a feature over the current tick. See O.FeatureMotion
+// for more information.
+type Motion struct {
+	// Velocity is the velocity at which the feature moves during the
+	// tick. Agents in contact with the feature are filtered against the
+	// velocity of the agent relative to the feature, i.e. an agent slides
+	// along a moving feature, and is pushed out of the way if the feature
+	// moves into the agent, e.g. a closing door.
+	Velocity vector.V
+
+	// Carry indicates the feature is a platform, e.g. a lift, which agents
+	// may stand on. Agents do not collide with a platform, and an agent
+	// whose center lies inside a platform is carried along with the
+	// platform velocity. The velocity of a carried agent is stored
+	// relative to the platform, i.e. an idle agent on a platform keeps a
+	// zero velocity.
+	Carry bool
+}
+
+const (
+	// reach is the distance by which the feature query of an agent is
+	// extended if features may move. Agents pushed by a feature end the
+	// tick exactly at the edge of the feature, and floating point errors
+	// would otherwise cause the contact to be missed in the next tick.
+	reach = 1e-6
+)
+
+var (
+	// still is the motion of a feature which does not move.
+	still = Motion{Velocity: vector.V{0, 0}}
+)
+
+// motion returns the motion of the input feature under the input hook.
+func motion(hook func(f feature.RO) Motion, f feature.RO) Motion {
+	if hook == nil {
+		return still
+	}
+	return hook(unwrapFeature(f))
+}
+
+// isCarrying checks if the agent a, with the altitude h, stands on the feature
+// f with the altitude k, i.e. if f is a platform which contains the center of
+// the agent.
+func isCarrying(a agent.RO, h Altitude, f feature.RO, k Altitude, m Motion) bool {
+	if !m.Carry || h&k == 0 {
+		return false
+	}
+	return contains(f.AABB(), a.Position())
+}
+
+// contains checks if the input point lies inside the input rectangle, including
+// the edges.
+func contains(r hyperrectangle.R, p vector.V) bool {
+	min, max := r.Min(), r.Max()
+	return p.X() >= min.X() && p.X() <= max.X() && p.Y() >= min.Y() && p.Y() <= max.Y()
+}
+
+// carrier returns the velocity of the platform which carries the input agent,
+// or nil if the agent is not carried. If several platforms contain the agent,
+// the platform with the smallest ID carries the agent. carrier uses the
+// feature buffer of the worker, and must be called before the colliding
+// features are queried.
+func (c *C) carrier(w *worker) vector.V {
+	if c.motion == nil {
+		return nil
+	}
+	c.queryFeatures(w, w.filterCarrier)
+	if len(w.fs) == 0 {
+		return nil
+	}
+	sortFeatures(w.fs)
+	return motion(c.motion, w.fs[0]).Velocity
+}
+
+// extend grows the AABB of the current agent of the worker by the input
+// distance.
+func (w *worker) extend(e float64) {
+	min, max := w.aabb.M().Min(), w.aabb.M().Max()
+	min.SetX(min.X() - e)
+	min.SetY(min.Y() - e)
+	max.SetX(max.X() + e)
+	max.SetY(max.Y() + e)
+}
+
+// isMoved checks if the input sleeping agent is in contact with a moving feature
+// or stands on a moving platform, in which case the agent is woken. isMoved uses
+// the buffers of the input worker.
+func (c *C) isMoved(w *worker, a agent.RO) bool {
+	if c.motion == nil {
+		return false
+	}
+	w.set(a)
+	if u := c.carrier(w); u != nil && !isZero(u) {
+		return true
+	}
+	w.extend(reach)
+	c.queryFeatures(w, w.filterFeature)
+	for _, f := range w.fs {
+		if !isZero(motion(c.motion, f).Velocity) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,304 @@
+package collider
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/downflux/go-database/agent"
+	"github.com/downflux/go-database/database"
+	"github.com/downflux/go-database/feature"
+	"github.com/downflux/go-geometry/2d/hyperrectangle"
+	"github.com/downflux/go-geometry/2d/vector"
+	"github.com/downflux/go-geometry/2d/vector/polar"
+	"github.com/downflux/go-geometry/epsilon"
+)
+
+func TestMotion(t *testing.T) {
+	type config struct {
+		name string
+		o    agent.O
+		f    feature.O
+		m    Motion
+
+		// want is the position of the agent after the tick.
+		want vector.V
+	}
+
+	moving := func(p vector.V, v vector.V) agent.O {
+		o := idle(p)
+		o.TargetVelocity = v
+		o.Velocity = v
+		o.Heading = polar.V{1, math.Atan2(v.Y(), v.X())}
+		return o
+	}
+
+	// wall is a feature whose left edge touches an agent at (0.5, 5).
+	wall := feature.O{AABB: *hyperrectangle.New(vector.V{1, 0}, vector.V{2, 10})}
+	platform := feature.O{AABB: *hyperrectangle.New(vector.V{0, 0}, vector.V{10, 10})}
+
+	configs := []config{
+		{
+			name: "Still",
+			o:    moving(vector.V{0.5, 5}, vector.V{1, 0}),
+			f:    wall,
+			m:    Motion{Velocity: vector.V{0, 0}},
+			want: vector.V{0.5, 5},
+		},
+		{
+			name: "Push",
+			o:    idle(vector.V{0.5, 5}),
+			f:    wall,
+			m:    Motion{Velocity: vector.V{-1, 0}},
+			want: vector.V{0.4, 5},
+		},
+		{
+			// An agent follows a feature which moves away from it
+			// at the speed of the feature.
+			name: "Retreat",
+			o:    moving(vector.V{0.5, 5}, vector.V{1, 0}),
+			f:    wall,
+			m:    Motion{Velocity: vector.V{0.5, 0}},
+			want: vector.V{0.55, 5},
+		},
+		{
+			// A feature which moves along its edge does not drag the
+			// agent along.
+			name: "Slide",
+			o:    idle(vector.V{0.5, 5}),
+			f:    wall,
+			m:    Motion{Velocity: vector.V{0, 1}},
+			want: vector.V{0.5, 5},
+		},
+		{
+			name: "Carry",
+			o:    idle(vector.V{5, 5}),
+			f:    platform,
+			m:    Motion{Velocity: vector.V{1, 0}, Carry: true},
+			want: vector.V{5.1, 5},
+		},
+		{
+			name: "Carry/Walk",
+			o:    moving(vector.V{5, 5}, vector.V{0, 1}),
+			f:    platform,
+			m:    Motion{Velocity: vector.V{1, 0}, Carry: true},
+			want: vector.V{5.1, 5.1},
+		},
+		{
+			// An agent whose center is off the platform is neither
+			// carried nor blocked by the platform.
+			name: "Carry/Off",
+			o:    moving(vector.V{10.2, 5}, vector.V{-1, 0}),
+			f:    platform,
+			m:    Motion{Velocity: vector.V{1, 0}, Carry: true},
+			want: vector.V{10.1, 5},
+		},
+	}
+
+	for _, c := range configs {
+		t.Run(c.name, func(t *testing.T) {
+			db := database.New(database.DefaultO)
+			collider := New(db, O{
+				PoolSize:      DefaultO.PoolSize,
+				FeatureMotion: func(f feature.RO) Motion { return c.m },
+			})
+			defer collider.Close()
+
+			a := db.InsertAgent(c.o)
+			db.InsertFeature(c.f)
+
+			collider.Tick(100 * time.Millisecond)
+
+			if got := db.GetAgentOrDie(a.ID()).Position(); !vector.Within(got, c.want) {
+				t.Errorf("Position() = %v, want = %v", got, c.want)
+			}
+		})
+	}
+}
+
+// TestMotionDoor verifies a closing door pushes an agent out of the way over
+// several ticks. The door may only overlap the agent in the tick in which the
+// door first reaches the agent, as contacts are not anticipated.
+func TestMotionDoor(t *testing.T) {
+	const d = 100 * time.Millisecond
+
+	u := vector.V{-1, 0}
+
+	db := database.New(database.DefaultO)
+	collider := New(db, O{
+		PoolSize:      DefaultO.PoolSize,
+		Sleep:         true,
+		FeatureMotion: func(f feature.RO) Motion { return Motion{Velocity: u} },
+	})
+	defer collider.Close()
+
+	a := db.InsertAgent(idle(vector.V{0, 5}))
+	door := db.InsertFeature(feature.O{AABB: *hyperrectangle.New(vector.V{1, 0}, vector.V{2, 10})})
+
+	overlaps := 0
+	for i := 0; i < 20; i++ {
+		collider.Tick(d)
+
+		// Move the door by its velocity once the tick has been
+		// committed.
+		aabb := door.AABB()
+		dx := u.X() * d.Seconds()
+		db.DeleteFeature(door.ID())
+		door = db.InsertFeature(feature.O{
+			AABB: *hyperrectangle.New(
+				vector.V{aabb.Min().X() + dx, aabb.Min().Y()},
+				vector.V{aabb.Max().X() + dx, aabb.Max().Y()},
+			),
+		})
+
+		p := db.GetAgentOrDie(a.ID()).Position()
+		if got, want := p.X()+R, door.AABB().Min().X(); got > want && !epsilon.Within(got, want) {
+			if overlaps++; got-want > -dx+1e-10 {
+				t.Errorf("[%v]: Position().X() + R = %v, want <= %v", i, got, want-dx)
+			}
+		}
+	}
+	if got, want := overlaps, 1; got > want {
+		t.Errorf("overlaps = %v, want <= %v", got, want)
+	}
+	if got, want := db.GetAgentOrDie(a.ID()).Position().X()+R, door.AABB().Min().X(); !epsilon.Within(got, want) {
+		t.Errorf("Position().X() + R = %v, want = %v", got, want)
+	}
+}
+
+// TestMotionCarry verifies the platform velocity does not accumulate in the
+// velocity of a carried agent over several ticks.
+func TestMotionCarry(t *testing.T) {
+	const (
+		d = 100 * time.Millisecond
+		n = 30
+	)
+
+	type config struct {
+		name  string
+		o     agent.O
+		sleep bool
+
+		// wall is an optional static feature on the platform.
+		wall *feature.O
+
+		// want is the position of the agent after n ticks.
+		want vector.V
+	}
+
+	u := vector.V{1, 0}
+	walk := func(p vector.V) agent.O {
+		o := idle(p)
+		o.TargetVelocity = vector.V{0, 0.1}
+		o.MaxAcceleration = 1
+		o.Heading = polar.V{1, math.Pi / 2}
+		return o
+	}
+	against := func(p vector.V) agent.O {
+		o := idle(p)
+		o.TargetVelocity = vector.V{-1, 0}
+		o.Heading = polar.V{1, math.Pi}
+		return o
+	}
+	slow := func(p vector.V) agent.O {
+		o := idle(p)
+		o.MaxAcceleration = 1
+		return o
+	}
+
+	configs := []config{
+		{name: "Idle", o: slow(vector.V{5, 5}), want: vector.V{8, 5}},
+		{name: "Idle/Sleep", o: slow(vector.V{5, 5}), sleep: true, want: vector.V{8, 5}},
+		// The agent accelerates to its target velocity relative to the
+		// platform over the first tick.
+		{name: "Walk", o: walk(vector.V{5, 5}), want: vector.V{8, 5.3}},
+		// An agent which walks against the platform stands still, but
+		// keeps its velocity relative to the platform.
+		{name: "Walk/Against", o: against(vector.V{5, 5}), want: vector.V{5, 5}},
+		// A carried agent which is blocked by a wall stands still, and
+		// does not commit the negated platform velocity.
+		{
+			name: "Blocked",
+			o:    slow(vector.V{5, 5}),
+			wall: &feature.O{AABB: *hyperrectangle.New(vector.V{5.5, 0}, vector.V{6, 10})},
+			want: vector.V{5, 5},
+		},
+	}
+
+	for _, c := range configs {
+		t.Run(c.name, func(t *testing.T) {
+			db := database.New(database.DefaultO)
+			platform := db.InsertFeature(feature.O{AABB: *hyperrectangle.New(vector.V{0, 0}, vector.V{100, 10})})
+			if c.wall != nil {
+				db.InsertFeature(*c.wall)
+			}
+			collider := New(db, O{
+				PoolSize: DefaultO.PoolSize,
+				Sleep:    c.sleep,
+				FeatureMotion: func(f feature.RO) Motion {
+					if f.ID() != platform.ID() {
+						return still
+					}
+					return Motion{Velocity: u, Carry: true}
+				},
+			})
+			defer collider.Close()
+
+			a := db.InsertAgent(c.o)
+
+			for i := 0; i < n; i++ {
+				collider.Tick(d)
+				if got, want := a.Velocity(), c.o.TargetVelocity; !vector.Within(got, want) {
+					t.Fatalf("[%v]: Velocity() = %v, want = %v", i, got, want)
+				}
+			}
+			if got := a.Position(); !vector.Within(got, c.want) {
+				t.Errorf("Position() = %v, want = %v", got, c.want)
+			}
+		})
+	}
+}
+
+// TestMotionSqueeze verifies a closing door does not push an agent through a
+// static wall, i.e. the agent is caught between the door and the wall. The door
+// eventually closes over the center of the agent.
+func TestMotionSqueeze(t *testing.T) {
+	const d = 100 * time.Millisecond
+
+	u := vector.V{-1, 0}
+
+	db := database.New(database.DefaultO)
+	a := db.InsertAgent(idle(vector.V{0, 5}))
+	wall := db.InsertFeature(feature.O{AABB: *hyperrectangle.New(vector.V{-2, 0}, vector.V{-0.6, 10})})
+	door := db.InsertFeature(feature.O{AABB: *hyperrectangle.New(vector.V{1, 0}, vector.V{2, 10})})
+
+	collider := New(db, O{
+		PoolSize: DefaultO.PoolSize,
+		FeatureMotion: func(f feature.RO) Motion {
+			if f.ID() == wall.ID() {
+				return still
+			}
+			return Motion{Velocity: u}
+		},
+	})
+	defer collider.Close()
+
+	for i := 0; i < 20; i++ {
+		collider.Tick(d)
+
+		aabb := door.AABB()
+		dx := u.X() * d.Seconds()
+		db.DeleteFeature(door.ID())
+		door = db.InsertFeature(feature.O{
+			AABB: *hyperrectangle.New(
+				vector.V{aabb.Min().X() + dx, aabb.Min().Y()},
+				vector.V{aabb.Max().X() + dx, aabb.Max().Y()},
+			),
+		})
+
+		p := db.GetAgentOrDie(a.ID()).Position()
+		if got, want := p.X()-R, wall.AABB().Max().X(); got < want && !epsilon.Within(got, want) {
+			t.Fatalf("[%v]: Position().X() - R = %v, want >= %v", i, got, want)
+		}
+	}
+}
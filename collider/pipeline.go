@@ -0,0 +1,317 @@
+package collider
+
+import (
+	"math"
+	"time"
+
+	"github.com/downflux/go-collider/kinematics"
+	"github.com/downflux/go-database/agent"
+	"github.com/downflux/go-database/feature"
+	"github.com/downflux/go-geometry/2d/vector"
+	"github.com/downflux/go-geometry/2d/vector/polar"
+)
+
+var (
+	// DefaultPipeline is the velocity pipeline used by the collider if no
+	// pipeline is specified.
+	//
+	// Joints are solved first, so that the joint corrections are subject to
+	// the remaining stages, followed by the resolution of jammed agents and
+	// right of way. The first collision passes then remove the components
+	// of the velocity which point into features and neighbors, after which
+	// the velocity is clamped by the physical limitations of the agent,
+	// carried along by the platform which the agent stands on, and clamped
+	// by the bounds of the world. The velocity may be further reduced to
+	// zero here. The second collision passes force the velocity to zero if
+	// the velocity has flip-flopped back into the forbidden zone of a
+	// feature or neighbor.
+	//
+	// N.B.: Joints, jam resolution, right of way, moving platforms, and
+	// world bounds are only enforced by the Joints, Unjam, Yield, Carry,
+	// and ClampBounds stages respectively. A custom pipeline which omits
+	// one of these stages silently disables the corresponding feature, even
+	// if the feature is configured in O.
+	DefaultPipeline = []Stage{
+		Joints,
+		Unjam,
+		Yield,
+		FeatureCollision,
+		NeighborCollision,
+		ClampVelocity,
+		ClampAcceleration,
+		ClampHeading,
+		Carry,
+		ClampBounds,
+		ClampFeatureCollision,
+		ClampNeighborCollision,
+	}
+)
+
+// State is the in-progress state of an agent in the velocity pipeline.
+//
+// The velocity is initialized to the target velocity of the agent, and the
+// heading is initialized to the current heading of the agent. Each stage of the
+// pipeline may then modify the velocity and heading in place.
+type State struct {
+	Agent agent.RO
+
+	// Duration is the duration by which the agent is advanced in the
+	// current tick.
+	Duration time.Duration
+
+	// Neighbors and Features are the agents and features which collide
+	// with the agent, sorted in a deterministic order. The slices are
+	// owned by the collider and must not be retained or modified.
+	Neighbors []agent.RO
+	Features  []feature.RO
+
+	Velocity vector.M
+	Heading  polar.M
+
+	// joints is the set of joints of the collider, and is used by the
+	// Joints stage.
+	joints *joints
+
+	// jams is the set of jammed agents of the collider, and is nil if jam
+	// tracking is disabled.
+	jams *jams
+
+	// bounds confines the agents of the world, and is nil if the world is
+	// unbounded.
+	bounds *bounds
+
+	// motion is the user-specified feature motion hook, and carry is the
+	// velocity of the platform which carries the agent, or nil if the
+	// agent is not carried. carried indicates the Carry stage has added
+	// the platform velocity to the velocity, and moving indicates the
+	// velocity was non-zero once the platform velocity was added.
+	motion  func(f feature.RO) Motion
+	carry   vector.V
+	carried bool
+	moving  bool
+
+	// priority is the user-specified priority hook, and nudge indicates
+	// idle agents step aside for moving teammates. These are used by the
+	// Yield stage.
+	priority func(a agent.RO) int
+	nudge    bool
+
+	// shape is the user-specified shape hook, and body is the current body
+	// of the agent. If shape is nil, agents are circles, and body is
+	// unset.
+	shape func(a agent.RO) kinematics.Shape
+	body  kinematics.Body
+
+	// clamped indicates a non-zero velocity was forced to zero by one of
+	// the second-pass collision clamps.
+	clamped bool
+}
+
+// Stage is a single step of the velocity pipeline.
+//
+// Stages are run in order for each agent, and are called concurrently across
+// agents. A stage must not modify the store, and should not allocate.
+type Stage interface {
+	Apply(s *State)
+}
+
+// StageFunc adapts an ordinary function into a Stage.
+type StageFunc func(s *State)
+
+func (f StageFunc) Apply(s *State) { f(s) }
+
+var (
+	// Joints adjusts the velocity towards satisfying the joints attached to
+	// the agent. See Joint for more information.
+	Joints Stage = StageFunc(func(s *State) { s.joints.solve(s) })
+
+	// Unjam applies the jam resolution strategy of the collider to the
+	// velocity of a jammed agent. See JamStrategy for more information.
+	Unjam Stage = StageFunc(func(s *State) { s.jams.unjam(s) })
+
+	// Yield steps the agent aside for a neighbor with right of way which
+	// wants to move into the agent. See O.Priority and O.Nudge for more
+	// information.
+	Yield Stage = StageFunc(func(s *State) {
+		if b := yieldTo(s); b != nil {
+			yield(s.Agent, b, speed(s.Agent, b), s.Velocity)
+		}
+	})
+
+	// FeatureCollision removes the velocity components which point into
+	// the colliding features. Velocities are filtered relative to moving
+	// features, which may push the agent. See Motion for more information.
+	//
+	// Moving features are filtered before static features, so that a
+	// moving feature cannot push the agent into a static feature, e.g. an
+	// agent caught between a closing door and a wall stays put.
+	FeatureCollision Stage = StageFunc(func(s *State) {
+		if s.shape != nil {
+			for _, f := range s.Features {
+				if u := s.velocity(f); !isZero(u) {
+					nx, ny, d := kinematics.FeatureContact(s.body, f)
+					kinematics.SetMovingContactVelocity(nx, ny, d, u, s.Duration, s.Velocity)
+				}
+			}
+			for _, f := range s.Features {
+				if u := s.velocity(f); isZero(u) {
+					if nx, ny, d := kinematics.FeatureContact(s.body, f); d <= 0 {
+						kinematics.SetContactVelocity(nx, ny, s.Velocity)
+					}
+				}
+			}
+			return
+		}
+		for _, f := range s.Features {
+			if u := s.velocity(f); !isZero(u) {
+				kinematics.SetMovingFeatureCollisionVelocity(s.Agent, f, u, s.Duration, s.Velocity)
+			}
+		}
+		for _, f := range s.Features {
+			if u := s.velocity(f); isZero(u) {
+				kinematics.SetFeatureCollisionVelocity(s.Agent, f, s.Velocity)
+			}
+		}
+	})
+
+	// NeighborCollision removes the velocity components which point into
+	// the colliding neighbors.
+	//
+	// N.B.: This method is not always reliable, and a multi-body collision
+	// may flip the velocity back into the body of an existing neighbor.
+	NeighborCollision Stage = StageFunc(func(s *State) {
+		if s.shape != nil {
+			for _, n := range s.Neighbors {
+				if nx, ny, d := kinematics.Contact(s.body, kinematics.Place(n, s.shape(n))); d <= 0 {
+					kinematics.SetContactVelocity(nx, ny, s.Velocity)
+				}
+			}
+			return
+		}
+		for _, n := range s.Neighbors {
+			kinematics.SetCollisionVelocity(s.Agent, n, s.Velocity)
+		}
+	})
+
+	ClampVelocity Stage = StageFunc(func(s *State) {
+		kinematics.ClampVelocity(s.Agent, s.Velocity)
+	})
+
+	ClampAcceleration Stage = StageFunc(func(s *State) {
+		kinematics.ClampAcceleration(s.Agent, s.Velocity, s.Duration)
+	})
+
+	// ClampHeading turns the agent towards the velocity, and limits the
+	// velocity to the direction of the heading. Shaped agents which would
+	// swing into a colliding neighbor or feature keep their current
+	// heading instead, and move along the current heading.
+	ClampHeading Stage = StageFunc(func(s *State) {
+		kinematics.ClampHeading(s.Agent, s.Duration, s.Velocity, s.Heading)
+		if s.shape == nil || s.Heading.Theta() == s.body.Theta {
+			return
+		}
+		if !isRotationBlocked(s, s.Heading.Theta()) {
+			s.body.Theta = s.Heading.Theta()
+			return
+		}
+		r := math.Hypot(s.Velocity.X(), s.Velocity.Y())
+		s.Heading.Copy(s.Agent.Heading())
+		sin, cos := math.Sincos(s.body.Theta)
+		s.Velocity.SetX(r * cos)
+		s.Velocity.SetY(r * sin)
+	})
+
+	// Carry adds the velocity of the platform which the agent stands on,
+	// if any, to the velocity. Stages before Carry operate on the velocity
+	// of the agent relative to the platform, and the platform velocity is
+	// subtracted again from the committed velocity of the agent, so that
+	// e.g. ClampAcceleration does not carry the platform velocity over
+	// into the next tick. An agent which is stopped by a later stage, e.g.
+	// by a wall, commits a zero velocity instead. See Motion for more
+	// information.
+	Carry Stage = StageFunc(func(s *State) {
+		if s.carry != nil {
+			s.Velocity.Add(s.carry)
+			s.carried = true
+			s.moving = !isZero(s.Velocity.V())
+		}
+	})
+
+	// ClampBounds limits the velocity so that the agent stays inside the
+	// bounds of the world. See O.Bounds for more information.
+	ClampBounds Stage = StageFunc(func(s *State) { s.bounds.clamp(s) })
+
+	// ClampFeatureCollision forces the velocity to zero if the velocity
+	// points into a colliding feature, or to the push velocity of a moving
+	// feature. As with FeatureCollision, static features are clamped last,
+	// and so override the push velocity of a moving feature.
+	ClampFeatureCollision Stage = StageFunc(func(s *State) {
+		moving := !isZero(s.Velocity.V())
+		if s.shape != nil {
+			for _, f := range s.Features {
+				if u := s.velocity(f); !isZero(u) {
+					nx, ny, d := kinematics.FeatureContact(s.body, f)
+					kinematics.ClampMovingContactVelocity(nx, ny, d, u, s.Duration, s.Velocity)
+				}
+			}
+			for _, f := range s.Features {
+				if u := s.velocity(f); isZero(u) {
+					if nx, ny, d := kinematics.FeatureContact(s.body, f); d <= 0 {
+						kinematics.ClampContactVelocity(nx, ny, s.Velocity)
+					}
+				}
+			}
+		} else {
+			for _, f := range s.Features {
+				if u := s.velocity(f); !isZero(u) {
+					kinematics.ClampMovingFeatureCollisionVelocity(s.Agent, f, u, s.Duration, s.Velocity)
+				}
+			}
+			for _, f := range s.Features {
+				if u := s.velocity(f); isZero(u) {
+					kinematics.ClampFeatureCollisionVelocity(s.Agent, f, s.Velocity)
+				}
+			}
+		}
+		s.clamped = s.clamped || (moving && isZero(s.Velocity.V()))
+	})
+
+	// ClampNeighborCollision forces the velocity to zero if the velocity
+	// points into a colliding neighbor.
+	ClampNeighborCollision Stage = StageFunc(func(s *State) {
+		moving := !isZero(s.Velocity.V())
+		if s.shape != nil {
+			for _, n := range s.Neighbors {
+				if nx, ny, d := kinematics.Contact(s.body, kinematics.Place(n, s.shape(n))); d <= 0 {
+					kinematics.ClampContactVelocity(nx, ny, s.Velocity)
+				}
+			}
+		} else {
+			for _, n := range s.Neighbors {
+				kinematics.ClampCollisionVelocity(s.Agent, n, s.Velocity)
+			}
+		}
+		s.clamped = s.clamped || (moving && isZero(s.Velocity.V()))
+	})
+)
+
+// isRotationBlocked checks if turning the shaped agent in place to the input
+// heading would swing the agent into a colliding neighbor or feature.
+func isRotationBlocked(s *State, theta float64) bool {
+	for _, f := range s.Features {
+		if kinematics.IsRotationBlocked(s.body, theta, kinematics.PlaceBox(f)) {
+			return true
+		}
+	}
+	for _, n := range s.Neighbors {
+		if kinematics.IsRotationBlocked(s.body, theta, kinematics.Place(n, s.shape(n))) {
+			return true
+		}
+	}
+	return false
+}
+
+// velocity returns the velocity of the input feature over the current tick.
+func (s *State) velocity(f feature.RO) vector.V {
+	return motion(s.motion, f).Velocity
+}
@@ -0,0 +1,52 @@
+package collider
+
+import (
+	"testing"
+	"time"
+
+	"github.com/downflux/go-database/database"
+	"github.com/downflux/go-geometry/2d/vector"
+)
+
+// slow halves the velocity of all agents, e.g. as a status effect.
+var slow = StageFunc(func(s *State) { s.Velocity.Scale(0.5) })
+
+func TestPipeline(t *testing.T) {
+	type config struct {
+		name     string
+		pipeline []Stage
+		want     vector.V
+	}
+
+	configs := []config{
+		{name: "Default", pipeline: nil, want: vector.V{0.2, 0}},
+		{name: "Slow", pipeline: append(DefaultPipeline[:len(DefaultPipeline):len(DefaultPipeline)], slow), want: vector.V{0.1, 0}},
+		// The target velocity is not clamped to the max velocity of the
+		// agent if the pipeline is empty.
+		{name: "Empty", pipeline: []Stage{}, want: vector.V{0.4, 0}},
+	}
+
+	for _, c := range configs {
+		t.Run(c.name, func(t *testing.T) {
+			db := database.New(database.DefaultO)
+			collider := New(db, O{
+				PoolSize: DefaultO.PoolSize,
+				Pipeline: c.pipeline,
+			})
+			defer collider.Close()
+
+			o := idle(vector.V{0, 0})
+			o.MaxVelocity = 10
+			o.MaxAcceleration = 1000
+			o.TargetVelocity = vector.V{20, 0}
+			o.Velocity = vector.V{10, 0}
+			x := db.InsertAgent(o).ID()
+
+			collider.Tick(20 * time.Millisecond)
+
+			if got := db.GetAgentOrDie(x).Position(); !vector.Within(got, c.want) {
+				t.Errorf("Position() = %v, want = %v", got, c.want)
+			}
+		})
+	}
+}
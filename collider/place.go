@@ -0,0 +1,171 @@
+package collider
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/downflux/go-database/agent"
+	"github.com/downflux/go-database/feature"
+	"github.com/downflux/go-geometry/2d/hyperrectangle"
+	"github.com/downflux/go-geometry/2d/vector"
+)
+
+// PlaceO describes the agent to be placed by Place and PlaceN.
+type PlaceO struct {
+	// Radius is the radius of the agent, and must be positive.
+	Radius float64
+
+	// Altitude is the altitude of the agent. Agents and features which do
+	// not share the altitude do not block the placement. If unset, the
+	// agent is placed on the ground.
+	Altitude Altitude
+
+	// MaxDistance is the maximum distance from the desired point at which
+	// the agent may be placed. If zero, only the desired point is checked.
+	MaxDistance float64
+}
+
+// Place returns the free position closest to the input point p for a new agent,
+// e.g. to spawn a unit at a rally point without overlapping existing agents or
+// features. The position is free if the agent does not overlap any agent or
+// solid feature in its altitude, and lies inside the bounds of the world.
+// Sensors and platforms do not block the placement. Place returns false if no
+// free position was found within the maximum distance of p.
+//
+// Positions are sampled on rings of increasing radius around p, where both the
+// rings and the samples on each ring are spaced half an agent radius apart;
+// the returned position is therefore the closest free position up to the
+// sampling resolution.
+//
+// N.B.: Collision layers and the FilterAgent and FilterFeature hooks are not
+// applied, as the agent does not yet exist. Place must not be called
+// concurrently with Tick.
+func (c *C) Place(p vector.V, o PlaceO) (vector.V, bool) {
+	ps := c.search(p, o, 1, nil)
+	if len(ps) == 0 {
+		return nil, false
+	}
+	return ps[0], true
+}
+
+// PlaceN returns up to n free positions around the input point p for a batch of
+// new agents, e.g. for a squad which spawns at a rally point. The agents do not
+// overlap one another, and are placed in order of increasing distance from p.
+// Fewer than n positions are returned if the area within the maximum distance
+// of p is full, and n must not be negative. See Place for more information.
+func (c *C) PlaceN(p vector.V, o PlaceO, n int) []vector.V {
+	if n < 0 {
+		panic(fmt.Sprintf("cannot place a negative number of agents %v", n))
+	}
+	return c.search(p, o, n, make([]vector.V, 0, n))
+}
+
+// search appends up to n free positions around the input point p to the input
+// buffer, in order of increasing distance from p.
+func (c *C) search(p vector.V, o PlaceO, n int, ps []vector.V) []vector.V {
+	if o.Radius <= 0 {
+		panic(fmt.Sprintf("cannot place an agent with a non-positive radius %v", o.Radius))
+	}
+	if o.MaxDistance < 0 {
+		panic(fmt.Sprintf("cannot place an agent with a negative maximum distance %v", o.MaxDistance))
+	}
+	if o.Altitude == 0 {
+		o.Altitude = AltitudeGround
+	}
+
+	s := o.Radius / 2
+	for i := 0; float64(i)*s <= o.MaxDistance && len(ps) < n; i++ {
+		// m is the number of samples on the ring, spaced (at most) s
+		// apart.
+		d := float64(i) * s
+		m := int(math.Max(1, math.Ceil(2*math.Pi*d/s)))
+		for j := 0; j < m && len(ps) < n; j++ {
+			theta := 2 * math.Pi * float64(j) / float64(m)
+			q := vector.V{p.X() + d*math.Cos(theta), p.Y() + d*math.Sin(theta)}
+			if c.torus != nil {
+				c.torus.wrap(q.M())
+			}
+			if c.isFree(q, o, ps) {
+				ps = append(ps, q)
+			}
+		}
+	}
+	return ps
+}
+
+// isFree checks if an agent at the input position q does not overlap any agent
+// or solid feature, nor any of the input agents which have already been
+// placed. Bodies which are only touching the agent do not block the placement.
+func (c *C) isFree(q vector.V, o PlaceO, placed []vector.V) bool {
+	r := o.Radius
+	if b := c.bounds; b != nil {
+		if q.X()-r < b.x0 || q.X()+r > b.x1 || q.Y()-r < b.y0 || q.Y()+r > b.y1 {
+			return false
+		}
+	}
+	for _, p := range placed {
+		if dx, dy := p.X()-q.X(), p.Y()-q.Y(); dx*dx+dy*dy < 4*r*r {
+			return false
+		}
+	}
+
+	aabb := *hyperrectangle.New(vector.V{q.X() - r, q.Y() - r}, vector.V{q.X() + r, q.Y() + r})
+	if !c.isClearAt(q, aabb, o) {
+		return false
+	}
+
+	// In a wrapped world, the agent may overlap bodies across the edges of
+	// the world.
+	if c.torus != nil {
+		var buf [8][2]float64
+		for _, s := range c.torus.offsets(aabb, &buf) {
+			u := vector.V{q.X() + s[0], q.Y() + s[1]}
+			if !c.isClearAt(u, *hyperrectangle.New(vector.V{u.X() - r, u.Y() - r}, vector.V{u.X() + r, u.Y() + r}), o) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// isClearAt checks if an agent at the input position q, with the input AABB,
+// does not overlap any agent or solid feature in the store.
+func (c *C) isClearAt(q vector.V, aabb hyperrectangle.R, o PlaceO) bool {
+	r, k := o.Radius, o.Altitude
+	w := c.workers[0]
+
+	w.ns = c.store.QueryAgents(aabb, func(b agent.RO) bool {
+		if k&c.altitudes.agent(b) == 0 {
+			return false
+		}
+		p := b.Position()
+		dx, dy := p.X()-q.X(), p.Y()-q.Y()
+		s := r + b.Radius()
+		return dx*dx+dy*dy < s*s
+	}, w.ns[:0])
+	n := len(w.ns)
+	for i := range w.ns {
+		w.ns[i] = nil
+	}
+	if n > 0 {
+		return false
+	}
+
+	w.fs = c.store.QueryFeatures(aabb, func(f feature.RO) bool {
+		if k&c.altitudes.featureAltitude(f) == 0 || !w.filter.isSolid(f) {
+			return false
+		}
+		// (x, y) is the point of the feature which is closest to the
+		// center of the agent.
+		g := f.AABB()
+		x := math.Max(g.Min().X(), math.Min(q.X(), g.Max().X()))
+		y := math.Max(g.Min().Y(), math.Min(q.Y(), g.Max().Y()))
+		dx, dy := q.X()-x, q.Y()-y
+		return dx*dx+dy*dy < r*r
+	}, w.fs[:0])
+	n = len(w.fs)
+	for i := range w.fs {
+		w.fs[i] = nil
+	}
+	return n == 0
+}
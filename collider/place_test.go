@@ -0,0 +1,165 @@
+package collider
+
+import (
+	"math"
+	"testing"
+
+	"github.com/downflux/go-database/agent"
+	"github.com/downflux/go-database/database"
+	"github.com/downflux/go-database/feature"
+	"github.com/downflux/go-geometry/2d/hyperrectangle"
+	"github.com/downflux/go-geometry/2d/vector"
+	"github.com/downflux/go-geometry/epsilon"
+)
+
+func TestPlace(t *testing.T) {
+	type config struct {
+		name   string
+		o      O
+		os     []agent.O
+		fs     []feature.O
+		p      vector.V
+		placeO PlaceO
+
+		// want is the expected position, and ok indicates a free
+		// position is expected to be found.
+		want vector.V
+		ok   bool
+	}
+
+	wall := feature.O{AABB: *hyperrectangle.New(vector.V{0, 0}, vector.V{10, 10})}
+
+	configs := []config{
+		{
+			name:   "Free",
+			o:      O{PoolSize: DefaultO.PoolSize},
+			p:      vector.V{5, 5},
+			placeO: PlaceO{Radius: R, MaxDistance: 5},
+			want:   vector.V{5, 5},
+			ok:     true,
+		},
+		{
+			// The new agent is placed next to the existing agent,
+			// on the first sample of the closest free ring.
+			name:   "Agent",
+			o:      O{PoolSize: DefaultO.PoolSize},
+			os:     []agent.O{idle(vector.V{5, 5})},
+			p:      vector.V{5, 5},
+			placeO: PlaceO{Radius: R, MaxDistance: 5},
+			want:   vector.V{5 + 2*R, 5},
+			ok:     true,
+		},
+		{
+			name:   "Agent/Air",
+			o:      O{PoolSize: DefaultO.PoolSize},
+			os:     []agent.O{idle(vector.V{5, 5})},
+			p:      vector.V{5, 5},
+			placeO: PlaceO{Radius: R, Altitude: AltitudeAir, MaxDistance: 5},
+			want:   vector.V{5, 5},
+			ok:     true,
+		},
+		{
+			name:   "Feature",
+			o:      O{PoolSize: DefaultO.PoolSize},
+			fs:     []feature.O{wall},
+			p:      vector.V{9, 5},
+			placeO: PlaceO{Radius: R, MaxDistance: 5},
+			want:   vector.V{10 + R, 5},
+			ok:     true,
+		},
+		{
+			name: "Feature/Sensor",
+			o: O{
+				PoolSize:  DefaultO.PoolSize,
+				Sensor:    func(f feature.RO) bool { return true },
+				OnTrigger: func(ts []Trigger) {},
+			},
+			fs:     []feature.O{wall},
+			p:      vector.V{9, 5},
+			placeO: PlaceO{Radius: R, MaxDistance: 5},
+			want:   vector.V{9, 5},
+			ok:     true,
+		},
+		{
+			name:   "Full",
+			o:      O{PoolSize: DefaultO.PoolSize},
+			fs:     []feature.O{wall},
+			p:      vector.V{5, 5},
+			placeO: PlaceO{Radius: R, MaxDistance: 1},
+			ok:     false,
+		},
+		{
+			// Rings are spaced by half the agent radius, and the
+			// closest sample inside the bounds lies on the second
+			// ring.
+			name:   "Bounds",
+			o:      O{PoolSize: DefaultO.PoolSize, Bounds: hyperrectangle.New(vector.V{0, 0}, vector.V{10, 10})},
+			p:      vector.V{0.2, 5},
+			placeO: PlaceO{Radius: R, MaxDistance: 5},
+			want:   vector.V{0.2 + R, 5},
+			ok:     true,
+		},
+	}
+
+	for _, c := range configs {
+		t.Run(c.name, func(t *testing.T) {
+			db := database.New(database.DefaultO)
+			collider := New(db, c.o)
+			defer collider.Close()
+
+			for _, o := range c.os {
+				db.InsertAgent(o)
+			}
+			for _, o := range c.fs {
+				db.InsertFeature(o)
+			}
+
+			got, ok := collider.Place(c.p, c.placeO)
+			if ok != c.ok {
+				t.Fatalf("Place() = _, %v, want = _, %v", ok, c.ok)
+			}
+			if ok && !vector.Within(got, c.want) {
+				t.Errorf("Place() = %v, want = %v", got, c.want)
+			}
+		})
+	}
+}
+
+func TestPlaceN(t *testing.T) {
+	const n = 10
+
+	db := database.New(database.DefaultO)
+	collider := New(db, O{PoolSize: DefaultO.PoolSize})
+	defer collider.Close()
+
+	a := db.InsertAgent(idle(vector.V{0, 0}))
+	db.InsertFeature(feature.O{AABB: *hyperrectangle.New(vector.V{1, -10}, vector.V{2, 10})})
+
+	ps := collider.PlaceN(vector.V{0, 0}, PlaceO{Radius: R, MaxDistance: 5}, n)
+	if got, want := len(ps), n; got != want {
+		t.Fatalf("len(PlaceN()) = %v, want = %v", got, want)
+	}
+
+	overlaps := func(p vector.V, q vector.V, r float64) bool {
+		d := math.Hypot(p.X()-q.X(), p.Y()-q.Y())
+		return d < r && !epsilon.Within(d, r)
+	}
+	for i, p := range ps {
+		if overlaps(p, a.Position(), 2*R) {
+			t.Errorf("PlaceN()[%v] = %v, which overlaps the agent at %v", i, p, a.Position())
+		}
+		if p.X()+R > 1 && p.X()-R < 2 {
+			t.Errorf("PlaceN()[%v] = %v, which overlaps the feature", i, p)
+		}
+		for j, q := range ps[:i] {
+			if overlaps(p, q, 2*R) {
+				t.Errorf("PlaceN()[%v] = %v, which overlaps PlaceN()[%v] = %v", i, p, j, q)
+			}
+		}
+		if i > 0 {
+			if d, e := vector.Magnitude(p), vector.Magnitude(ps[i-1]); d < e && !epsilon.Within(d, e) {
+				t.Errorf("|PlaceN()[%v]| = %v, want >= %v", i, d, e)
+			}
+		}
+	}
+}
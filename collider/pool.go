@@ -0,0 +1,111 @@
+package collider
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// pool is a set of long-lived worker goroutines. The same task is broadcast to
+// every worker, and each worker is told its own index so that the task may
+// partition the work between workers.
+type pool struct {
+	in []chan func(w int)
+	wg sync.WaitGroup
+}
+
+func newPool(n int) *pool {
+	p := &pool{
+		in: make([]chan func(w int), n),
+	}
+	for i := range p.in {
+		p.in[i] = make(chan func(w int))
+		go p.work(i)
+	}
+	return p
+}
+
+func (p *pool) work(w int) {
+	for f := range p.in[w] {
+		f(w)
+		p.wg.Done()
+	}
+}
+
+func (p *pool) size() int { return len(p.in) }
+
+// run executes the task on all workers and blocks until every worker has
+// returned.
+func (p *pool) run(f func(w int)) {
+	p.wg.Add(len(p.in))
+	for _, ch := range p.in {
+		ch <- f
+	}
+	p.wg.Wait()
+}
+
+// close stops all workers. The pool must not be used after it has been closed.
+func (p *pool) close() {
+	for _, ch := range p.in {
+		close(ch)
+	}
+}
+
+// cursor is an atomic counter padded to a full cache line to avoid false
+// sharing between workers.
+type cursor struct {
+	atomic.Int64
+	_ [56]byte
+}
+
+// ranges distributes the index range [0, n) between a fixed number of workers.
+// Each worker is initially assigned a contiguous block of indices, which it
+// claims in fixed-size chunks. Once a worker has exhausted its own block, it
+// steals chunks from the blocks of other workers.
+//
+// Claiming a chunk is a single atomic add on the cursor of the block, so the
+// owner and any thieves will never be handed overlapping chunks.
+type ranges struct {
+	chunk int64
+	next  []cursor
+	end   []int64
+}
+
+func newRanges(workers int, chunk int) *ranges {
+	return &ranges{
+		chunk: int64(chunk),
+		next:  make([]cursor, workers),
+		end:   make([]int64, workers),
+	}
+}
+
+// reset partitions [0, n) into contiguous blocks. reset must not be called
+// concurrently with claim.
+func (r *ranges) reset(n int) {
+	k := int64(len(r.end))
+	for i := int64(0); i < k; i++ {
+		r.next[i].Store(i * int64(n) / k)
+		r.end[i] = (i + 1) * int64(n) / k
+	}
+}
+
+// claim returns the next chunk [lo, hi) of indices to be processed by worker
+// w. If all indices have been claimed, claim returns false.
+func (r *ranges) claim(w int) (int, int, bool) {
+	k := len(r.end)
+	for i := 0; i < k; i++ {
+		v := (w + i) % k
+		if r.next[v].Load() >= r.end[v] {
+			continue
+		}
+		lo := r.next[v].Add(r.chunk) - r.chunk
+		if lo >= r.end[v] {
+			continue
+		}
+		hi := lo + r.chunk
+		if hi > r.end[v] {
+			hi = r.end[v]
+		}
+		return int(lo), int(hi), true
+	}
+	return 0, 0, false
+}
@@ -0,0 +1,90 @@
+package collider
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+)
+
+func TestRanges(t *testing.T) {
+	type config struct {
+		name    string
+		workers int
+		chunk   int
+		n       int
+	}
+
+	configs := []config{}
+	for _, workers := range []int{1, 2, 7} {
+		for _, chunk := range []int{1, 3, 64} {
+			for _, n := range []int{0, 1, 10, 1000} {
+				configs = append(configs, config{
+					name:    fmt.Sprintf("Workers=%v/Chunk=%v/N=%v", workers, chunk, n),
+					workers: workers,
+					chunk:   chunk,
+					n:       n,
+				})
+			}
+		}
+	}
+
+	for _, c := range configs {
+		t.Run(c.name, func(t *testing.T) {
+			r := newRanges(c.workers, c.chunk)
+			counts := make([]int, c.n)
+
+			var mu sync.Mutex
+			var wg sync.WaitGroup
+
+			// Reuse the ranges to ensure reset clears any state
+			// from the previous round.
+			for round := 0; round < 2; round++ {
+				r.reset(c.n)
+				wg.Add(c.workers)
+				for i := 0; i < c.workers; i++ {
+					go func(w int) {
+						defer wg.Done()
+						for {
+							lo, hi, ok := r.claim(w)
+							if !ok {
+								return
+							}
+							if hi-lo > c.chunk || hi <= lo {
+								t.Errorf("claim() = [%v, %v), want a non-empty chunk of at most %v", lo, hi, c.chunk)
+							}
+							mu.Lock()
+							for j := lo; j < hi; j++ {
+								counts[j]++
+							}
+							mu.Unlock()
+						}
+					}(i)
+				}
+				wg.Wait()
+			}
+
+			for i, got := range counts {
+				if got != 2 {
+					t.Errorf("index %v was claimed %v times, want = %v", i, got, 2)
+				}
+			}
+		})
+	}
+}
+
+func TestPool(t *testing.T) {
+	const n = 8
+
+	p := newPool(n)
+	defer p.close()
+
+	for round := 0; round < 3; round++ {
+		got := make([]bool, n)
+		p.run(func(w int) { got[w] = true })
+		for w, ok := range got {
+			if !ok {
+				t.Errorf("worker %v was not run in round %v", w, round)
+			}
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package collider
+
+import (
+	"math"
+
+	"github.com/downflux/go-database/agent"
+	"github.com/downflux/go-database/filters"
+	"github.com/downflux/go-geometry/2d/vector"
+)
+
+// outranks checks if the agent a has right of way over the agent b under the
+// input priority hook. Ties are broken by ID, where the agent with the smaller
+// ID has right of way.
+func outranks(priority func(a agent.RO) int, a agent.RO, b agent.RO) bool {
+	if priority != nil {
+		if p, q := priority(a), priority(b); p != q {
+			return p > q
+		}
+	}
+	return a.ID() < b.ID()
+}
+
+// isIdle checks if the input agent does not want to move.
+func isIdle(a agent.RO) bool {
+	t := a.TargetVelocity()
+	return t.X() == 0 && t.Y() == 0
+}
+
+// isInPath checks if the agent a is in the path of the agent b, i.e. if b
+// wants to move into a.
+func isInPath(a agent.RO, b agent.RO) bool {
+	p, q, t := a.Position(), b.Position(), b.TargetVelocity()
+	dx, dy := p.X()-q.X(), p.Y()-q.Y()
+	c := dx*t.X() + dy*t.Y()
+	if c <= 0 {
+		return false
+	}
+
+	// The offset of a perpendicular to the path of b must be smaller than
+	// the sum of the radii of the two agents.
+	r := a.Radius() + b.Radius()
+	return dx*dx+dy*dy-c*c/(t.X()*t.X()+t.Y()*t.Y()) < r*r
+}
+
+// yieldTo returns the neighbor with the highest priority which the current
+// agent should step aside for, or nil if the agent keeps its path.
+//
+// An agent yields to a neighbor which wants to move into the agent if the
+// neighbor has a strictly higher priority, or if nudging is enabled, the agent
+// is idle, and the neighbor is a moving teammate.
+func yieldTo(s *State) agent.RO {
+	if s.priority == nil && !s.nudge {
+		return nil
+	}
+	a := s.Agent
+
+	var b agent.RO
+	for _, n := range s.Neighbors {
+		if !isInPath(a, n) {
+			continue
+		}
+		ok := s.priority != nil && s.priority(n) > s.priority(a)
+		ok = ok || (s.nudge && isIdle(a) && filters.AgentIsTeammate(a, n))
+		if ok && (b == nil || outranks(s.priority, n, b)) {
+			b = n
+		}
+	}
+	return b
+}
+
+// speed returns the speed at which the agent a steps aside for the agent b,
+// i.e. the target speed of a, or the target speed of b if a is idle.
+func speed(a agent.RO, b agent.RO) float64 {
+	t := a.TargetVelocity()
+	if isIdle(a) {
+		t = b.TargetVelocity()
+	}
+	return math.Hypot(t.X(), t.Y())
+}
+
+// yield sets the input velocity of the agent a to step out of the path of the
+// agent b at the input speed r, i.e. perpendicular to the target velocity of b.
+// If a lies directly in the path of b, a steps to the right of b.
+func yield(a agent.RO, b agent.RO, r float64, v vector.M) {
+	// (tx, ty) is the unit direction of b, and (ux, uy) is the offset of a
+	// from b, perpendicular to the direction of b.
+	t := b.TargetVelocity()
+	tx, ty := t.X(), t.Y()
+	if l := math.Hypot(tx, ty); l > 0 {
+		tx, ty = tx/l, ty/l
+	}
+	p, q := a.Position(), b.Position()
+	dx, dy := p.X()-q.X(), p.Y()-q.Y()
+	c := dx*tx + dy*ty
+	ux, uy := dx-c*tx, dy-c*ty
+
+	l := math.Hypot(ux, uy)
+	if l <= 1e-3*a.Radius() {
+		ux, uy, l = ty, -tx, 1
+		if tx == 0 && ty == 0 {
+			// b has no direction, so step directly away from b.
+			ux, uy, l = dx, dy, math.Hypot(dx, dy)
+		}
+	}
+	if l == 0 {
+		return
+	}
+	v.SetX(r * ux / l)
+	v.SetY(r * uy / l)
+}
@@ -0,0 +1,82 @@
+package collider
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/downflux/go-bvh/id"
+	"github.com/downflux/go-database/agent"
+	"github.com/downflux/go-database/database"
+	"github.com/downflux/go-geometry/2d/vector"
+	"github.com/downflux/go-geometry/2d/vector/polar"
+)
+
+func TestPriority(t *testing.T) {
+	type config struct {
+		name     string
+		priority bool
+		nudge    bool
+
+		// idle indicates the agent b does not want to move, and
+		// otherwise b moves head-on into a.
+		idle bool
+
+		// want indicates the agent a is expected to pass b, and to
+		// stay close to its original path.
+		want bool
+	}
+
+	configs := []config{
+		{name: "Equal", priority: false, want: false},
+		{name: "Priority", priority: true, want: true},
+		{name: "Priority/Idle", priority: true, idle: true, want: true},
+		{name: "Nudge", nudge: true, idle: true, want: true},
+		{name: "Nudge/Disabled", nudge: false, idle: true, want: false},
+	}
+
+	for _, c := range configs {
+		t.Run(c.name, func(t *testing.T) {
+			var a id.ID
+
+			// cargo gives right of way to the agent a.
+			var cargo func(b agent.RO) int
+			if c.priority {
+				cargo = func(b agent.RO) int {
+					if b.ID() == a {
+						return 1
+					}
+					return 0
+				}
+			}
+
+			db := database.New(database.DefaultO)
+			collider := New(db, O{
+				PoolSize: DefaultO.PoolSize,
+				Priority: cargo,
+				Nudge:    c.nudge,
+			})
+			defer collider.Close()
+
+			oa := idle(vector.V{0, 0})
+			oa.TargetVelocity = vector.V{1, 0}
+			ob := idle(vector.V{2 * R, 0})
+			if !c.idle {
+				ob.TargetVelocity = vector.V{-1, 0}
+				ob.Heading = polar.V{1, math.Pi}
+			}
+
+			a = db.InsertAgent(oa).ID()
+			b := db.InsertAgent(ob).ID()
+
+			for i := 0; i < 500; i++ {
+				collider.Tick(20 * time.Millisecond)
+			}
+
+			p, q := db.GetAgentOrDie(a).Position(), db.GetAgentOrDie(b).Position()
+			if got := p.X() > q.X()+2*R && math.Abs(p.Y()) < R/10; got != c.want {
+				t.Errorf("passed = %v, want = %v (a = %v, b = %v)", got, c.want, p, q)
+			}
+		})
+	}
+}
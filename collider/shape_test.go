@@ -0,0 +1,118 @@
+package collider
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/downflux/go-collider/kinematics"
+	"github.com/downflux/go-database/agent"
+	"github.com/downflux/go-database/database"
+	"github.com/downflux/go-database/feature"
+	"github.com/downflux/go-geometry/2d/hyperrectangle"
+	"github.com/downflux/go-geometry/2d/vector"
+	"github.com/downflux/go-geometry/epsilon"
+)
+
+func TestShapeCorridor(t *testing.T) {
+	type config struct {
+		name  string
+		shape func(a agent.RO) kinematics.Shape
+
+		// want indicates the agent is expected to pass through the
+		// corridor.
+		want bool
+	}
+
+	configs := []config{
+		{
+			name:  "Circle",
+			shape: nil,
+			want:  false,
+		},
+		{
+			// The capsule is narrower than the corridor, even though
+			// its bounding circle is not.
+			name: "Capsule",
+			shape: func(a agent.RO) kinematics.Shape {
+				return kinematics.Shape{Length: 0.35, Radius: 0.15}
+			},
+			want: true,
+		},
+	}
+
+	for _, c := range configs {
+		t.Run(c.name, func(t *testing.T) {
+			db := database.New(database.DefaultO)
+			collider := New(db, O{
+				PoolSize: DefaultO.PoolSize,
+				Shape:    c.shape,
+			})
+			defer collider.Close()
+
+			o := idle(vector.V{0, 0})
+			o.TargetVelocity = vector.V{5, 0}
+			x := db.InsertAgent(o).ID()
+
+			db.InsertFeature(feature.O{AABB: *hyperrectangle.New(vector.V{1, 0.2}, vector.V{3, 1})})
+			db.InsertFeature(feature.O{AABB: *hyperrectangle.New(vector.V{1, -1}, vector.V{3, -0.2})})
+
+			for i := 0; i < 100; i++ {
+				collider.Tick(20 * time.Millisecond)
+			}
+
+			if got := db.GetAgentOrDie(x).Position().X() > 3; got != c.want {
+				t.Errorf("passed = %v, want = %v", got, c.want)
+			}
+		})
+	}
+}
+
+func TestShapeRotation(t *testing.T) {
+	type config struct {
+		name string
+		wall bool
+		want float64
+	}
+
+	// The agent turns at π rad/s for 10 ticks of 20ms.
+	configs := []config{
+		{name: "Free", wall: false, want: math.Pi / 5},
+		{name: "Blocked", wall: true, want: 0},
+	}
+
+	for _, c := range configs {
+		t.Run(c.name, func(t *testing.T) {
+			db := database.New(database.DefaultO)
+			collider := New(db, O{
+				PoolSize: DefaultO.PoolSize,
+				Shape: func(a agent.RO) kinematics.Shape {
+					return kinematics.Shape{Length: 0.45, Width: 0.1}
+				},
+			})
+			defer collider.Close()
+
+			o := idle(vector.V{0, 0})
+			o.TargetVelocity = vector.V{0, 1}
+			x := db.InsertAgent(o).ID()
+
+			// The wall is clear of the agent, but the ends of the
+			// agent would swing into the wall if the agent turns.
+			if c.wall {
+				db.InsertFeature(feature.O{AABB: *hyperrectangle.New(vector.V{-1, 0.12}, vector.V{1, 1})})
+			}
+
+			for i := 0; i < 10; i++ {
+				collider.Tick(20 * time.Millisecond)
+			}
+
+			a := db.GetAgentOrDie(x)
+			if got := a.Heading().Theta(); !epsilon.Absolute(1e-10).Within(got, c.want) {
+				t.Errorf("Theta() = %v, want = %v", got, c.want)
+			}
+			if got := a.Position().Y(); got < 0 || got > 0.12 {
+				t.Errorf("Y() = %v, want = [0, 0.12]", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,204 @@
+package collider
+
+import (
+	"sync/atomic"
+
+	"github.com/downflux/go-bvh/id"
+	"github.com/downflux/go-database/agent"
+	"github.com/downflux/go-geometry/2d/vector"
+)
+
+const (
+	fResting uint32 = 1 << iota
+	fAsleep
+)
+
+// sleep tracks the set of sleeping agents across ticks.
+//
+// An agent is resting if both its velocity and target velocity are zero, in
+// which case the generated velocity of the agent is always zero, unless the
+// agent is pushed or carried by a moving feature (see O.FeatureMotion). A resting
+// agent which is not in contact with any active (i.e. non-resting) agent falls
+// asleep at the end of the tick, and is skipped during velocity generation
+// until it is woken.
+//
+// Sleeping agents which were in contact with one another when they fell asleep
+// form an island, and the island is woken as a whole when any of its members
+// is woken, i.e. when an active agent pushes into a member, or when a member is
+// no longer resting. Islands which are pushed during a tick are woken at the end
+// of the tick; this is safe, as the generated velocity of a resting agent does
+// not depend on its neighbors, unless the agent is nudged (see O.Nudge), in
+// which case the agent steps aside one tick late.
+type sleep struct {
+	// asleep maps each sleeping agent to the resting agents it was in
+	// contact with when it fell asleep.
+	asleep map[id.ID][]id.ID
+
+	// index maps the agent IDs to their index in the current tick.
+	index map[id.ID]int
+
+	// state contains the resting and sleeping flags of each agent in the
+	// current tick, and is only written to serially. woken is set
+	// concurrently by the workers when an active agent pushes into a
+	// sleeping agent.
+	state []uint32
+	woken []atomic.Bool
+
+	queue []id.ID
+	free  [][]id.ID
+
+	// joints is the set of joints of the collider. Jointed agents may be
+	// pulled by the joint, and are never resting.
+	joints *joints
+}
+
+// nap records a resting agent which will fall asleep at the end of the tick,
+// along with the range of its resting contacts in the worker contact buffer.
+type nap struct {
+	i  int
+	lo int
+	hi int
+}
+
+func newSleep(j *joints) *sleep {
+	return &sleep{
+		joints: j,
+		asleep: map[id.ID][]id.ID{},
+		index:  map[id.ID]int{},
+	}
+}
+
+func resting(a agent.RO) bool {
+	v, t := a.Velocity(), a.TargetVelocity()
+	return v.X() == 0 && v.Y() == 0 && t.X() == 0 && t.Y() == 0
+}
+
+// update classifies the agents of the current tick, and wakes any island with
+// a member which is no longer resting. update is called serially before
+// velocity generation.
+func (s *sleep) update(agents []agent.RO) {
+	n := len(agents)
+	if cap(s.state) < n {
+		s.state = make([]uint32, n)
+		s.woken = make([]atomic.Bool, n)
+	}
+	s.state = s.state[:n]
+	s.woken = s.woken[:n]
+
+	for x := range s.index {
+		delete(s.index, x)
+	}
+
+	m := 0
+	for i, a := range agents {
+		s.index[a.ID()] = i
+		s.woken[i].Store(false)
+
+		var f uint32
+		if resting(a) && !s.joints.has(a.ID()) {
+			f |= fResting
+		}
+		if _, ok := s.asleep[a.ID()]; ok {
+			m++
+			if f&fResting != 0 {
+				f |= fAsleep
+			} else {
+				s.queue = append(s.queue, a.ID())
+			}
+		}
+		s.state[i] = f
+	}
+
+	// Remove agents which have been deleted from the database.
+	if m < len(s.asleep) {
+		for x := range s.asleep {
+			if _, ok := s.index[x]; !ok {
+				s.free = append(s.free, s.asleep[x][:0])
+				delete(s.asleep, x)
+			}
+		}
+	}
+
+	s.wake()
+}
+
+func (s *sleep) isAsleep(i int) bool { return s.state[i]&fAsleep != 0 }
+
+// observe inspects the contacts of an awake agent after its velocity has been
+// generated. An active agent wakes any sleeping contact which it is pushing
+// into, and a resting agent with no active contacts is recorded into the
+// worker as ready to fall asleep. A resting agent which is pushed or carried by
+// a moving feature, i.e. with a non-zero generated velocity v, stays awake.
+func (s *sleep) observe(w *worker, i int, a agent.RO, v vector.V, ns []agent.RO) {
+	if s.state[i]&fResting == 0 {
+		p, t := a.Position(), a.TargetVelocity()
+		for _, n := range ns {
+			j, ok := s.index[n.ID()]
+			if !ok || s.state[j]&fAsleep == 0 {
+				continue
+			}
+			q := n.Position()
+			if (q.X()-p.X())*t.X()+(q.Y()-p.Y())*t.Y() > 0 {
+				s.woken[j].Store(true)
+			}
+		}
+		return
+	}
+
+	if !isZero(v) {
+		return
+	}
+	for _, n := range ns {
+		if j, ok := s.index[n.ID()]; !ok || s.state[j]&fResting == 0 {
+			return
+		}
+	}
+
+	lo := len(w.contacts)
+	for _, n := range ns {
+		w.contacts = append(w.contacts, n.ID())
+	}
+	w.naps = append(w.naps, nap{i: i, lo: lo, hi: len(w.contacts)})
+}
+
+// settle puts the agents recorded by the workers to sleep and wakes the islands
+// of any sleeping agents which were pushed during the tick. settle is called
+// serially once the tick has been committed.
+func (s *sleep) settle(agents []agent.RO, workers []*worker) {
+	for _, w := range workers {
+		for _, n := range w.naps {
+			var cs []id.ID
+			if k := len(s.free); k > 0 {
+				cs, s.free = s.free[k-1], s.free[:k-1]
+			}
+			s.asleep[agents[n.i].ID()] = append(cs, w.contacts[n.lo:n.hi]...)
+		}
+	}
+	for i := range s.woken {
+		if s.woken[i].Load() {
+			s.queue = append(s.queue, agents[i].ID())
+		}
+	}
+	s.wake()
+}
+
+// wake wakes the islands of all agents in the queue.
+func (s *sleep) wake() {
+	for k := len(s.queue); k > 0; k = len(s.queue) {
+		x := s.queue[k-1]
+		s.queue = s.queue[:k-1]
+
+		cs, ok := s.asleep[x]
+		if !ok {
+			continue
+		}
+		delete(s.asleep, x)
+
+		s.queue = append(s.queue, cs...)
+		s.free = append(s.free, cs[:0])
+
+		if i, ok := s.index[x]; ok {
+			s.state[i] &^= fAsleep
+		}
+	}
+}
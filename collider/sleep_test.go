@@ -0,0 +1,275 @@
+package collider
+
+import (
+	"fmt"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/downflux/go-bvh/id"
+	"github.com/downflux/go-database/agent"
+	"github.com/downflux/go-database/database"
+	"github.com/downflux/go-database/flags/size"
+	"github.com/downflux/go-geometry/2d/vector"
+	"github.com/downflux/go-geometry/2d/vector/polar"
+)
+
+func idle(p vector.V) agent.O {
+	return agent.O{
+		Position:           p,
+		TargetPosition:     p,
+		TargetVelocity:     vector.V{0, 0},
+		Velocity:           vector.V{0, 0},
+		MaxVelocity:        10,
+		MaxAcceleration:    10,
+		MaxAngularVelocity: math.Pi,
+		Heading:            polar.V{1, 0},
+		Radius:             R,
+		Mass:               1,
+		Size:               size.FSmall,
+	}
+}
+
+func TestSleep(t *testing.T) {
+	type step struct {
+		// f is an optional mutation to the database before the tick.
+		f    func(db *database.DB, xs []id.ID)
+		want int
+	}
+
+	type config struct {
+		name  string
+		os    []agent.O
+		steps []step
+	}
+
+	// island is a row of touching idle agents.
+	island := []agent.O{
+		idle(vector.V{0, 0}),
+		idle(vector.V{1, 0}),
+		idle(vector.V{2, 0}),
+	}
+
+	configs := []config{
+		{
+			name: "Isolated",
+			os:   []agent.O{idle(vector.V{0, 0})},
+			steps: []step{
+				{want: 0},
+				{want: 1},
+				{want: 1},
+				{
+					f: func(db *database.DB, xs []id.ID) {
+						db.SetAgentTargetVelocity(xs[0], vector.V{1, 0})
+					},
+					want: 0,
+				},
+			},
+		},
+		{
+			name: "Island/Wake",
+			os:   island,
+			steps: []step{
+				{want: 0},
+				{want: 3},
+				// Waking the tail of the island wakes the
+				// whole island.
+				{
+					f: func(db *database.DB, xs []id.ID) {
+						db.SetAgentTargetVelocity(xs[2], vector.V{1, 0})
+					},
+					want: 0,
+				},
+			},
+		},
+		{
+			name: "Island/Push",
+			os: append(island[:3:3], func() agent.O {
+				o := idle(vector.V{-1, 0})
+				o.TargetVelocity = vector.V{1, 0}
+				o.Velocity = vector.V{1, 0}
+				return o
+			}()),
+			steps: []step{
+				{want: 0},
+				// The head of the island is in contact with
+				// the active agent, and stays awake.
+				{want: 2},
+				{
+					f: func(db *database.DB, xs []id.ID) {
+						db.SetAgentTargetVelocity(xs[3], vector.V{0, 0})
+						db.SetAgentVelocity(xs[3], vector.V{0, 0})
+					},
+					want: 2,
+				},
+				{want: 4},
+			},
+		},
+		{
+			name: "Island/PushSleeping",
+			os:   append(island[:3:3], idle(vector.V{-2, 0})),
+			steps: []step{
+				{want: 0},
+				{want: 4},
+				// The island is pushed during the tick, and
+				// is woken at the end of the tick.
+				{
+					f: func(db *database.DB, xs []id.ID) {
+						db.SetAgentPosition(xs[3], vector.V{-1, 0})
+						db.SetAgentTargetVelocity(xs[3], vector.V{1, 0})
+					},
+					want: 3,
+				},
+				// The head of the island stays awake while in
+				// contact with the active agent.
+				{want: 0},
+				{want: 2},
+			},
+		},
+	}
+
+	for _, c := range configs {
+		t.Run(c.name, func(t *testing.T) {
+			var got Stats
+
+			db := database.New(database.DefaultO)
+			collider := New(db, O{
+				PoolSize: DefaultO.PoolSize,
+				Sleep:    true,
+				OnStats:  func(s Stats) { got = s },
+			})
+			defer collider.Close()
+
+			var xs []id.ID
+			for _, o := range c.os {
+				xs = append(xs, db.InsertAgent(o).ID())
+			}
+
+			for i, s := range c.steps {
+				if s.f != nil {
+					s.f(db, xs)
+				}
+				collider.Tick(20 * time.Millisecond)
+				if got.Sleeping != s.want {
+					t.Errorf("Sleeping = %v, want = %v (step %v)", got.Sleeping, s.want, i)
+				}
+			}
+		})
+	}
+}
+
+// TestSleepConsistent verifies sleep tracking does not change the results of
+// the simulation.
+func TestSleepConsistent(t *testing.T) {
+	const n = 400
+
+	var os []agent.O
+	max := math.Sqrt(float64(n) * math.Pi * R * R / 0.3)
+	for i := 0; i < n; i++ {
+		o := idle(rv(0, max))
+		// Most agents are idle, and the remaining agents move through
+		// the idle crowd.
+		if i%5 == 0 {
+			o.TargetVelocity = rv(-5, 5)
+		}
+		os = append(os, o)
+	}
+
+	var dbs []*database.DB
+	var sleeping int
+	for _, sleep := range []bool{false, true} {
+		db := database.New(database.DefaultO)
+		var xs []id.ID
+		for _, o := range os {
+			xs = append(xs, db.InsertAgent(o).ID())
+		}
+
+		c := New(db, O{
+			PoolSize: DefaultO.PoolSize,
+			Sleep:    sleep,
+			OnStats:  func(s Stats) { sleeping += s.Sleeping },
+		})
+		for i := 0; i < 50; i++ {
+			// Periodically stop and start agents.
+			if i%10 == 0 {
+				for j, x := range xs {
+					if j%7 == i%7 {
+						db.SetAgentTargetVelocity(x, vector.V{0, 0})
+					}
+					if j%11 == i%11 {
+						db.SetAgentTargetVelocity(x, vector.V{float64(i%3 - 1), float64(j%3 - 1)})
+					}
+				}
+			}
+			c.Tick(20 * time.Millisecond)
+		}
+		c.Close()
+
+		dbs = append(dbs, db)
+	}
+
+	if sleeping == 0 {
+		t.Fatalf("Sleeping = 0, want > 0")
+	}
+
+	for a := range dbs[0].ListAgents() {
+		b := dbs[1].GetAgentOrDie(a.ID())
+		if got, want := b.Position(), a.Position(); !vector.Within(got, want) {
+			t.Errorf("Position() = %v, want = %v", got, want)
+		}
+		if got, want := b.Velocity(), a.Velocity(); !vector.Within(got, want) {
+			t.Errorf("Velocity() = %v, want = %v", got, want)
+		}
+		if got, want := b.Heading(), a.Heading(); !polar.Within(got, want) {
+			t.Errorf("Heading() = %v, want = %v", got, want)
+		}
+	}
+}
+
+func BenchmarkSleep(b *testing.B) {
+	type config struct {
+		name string
+		n    int
+		// idle is the fraction of agents which are stationary.
+		idle  float64
+		sleep bool
+	}
+
+	configs := []config{}
+	for _, idle := range []float64{0, 0.5, 0.9} {
+		for _, sleep := range []bool{false, true} {
+			configs = append(configs, config{
+				name:  fmt.Sprintf("N=%v/Idle=%v/Sleep=%v", 1000, idle, sleep),
+				n:     1000,
+				idle:  idle,
+				sleep: sleep,
+			})
+		}
+	}
+
+	for _, c := range configs {
+		b.Run(c.name, func(b *testing.B) {
+			max := math.Sqrt(float64(c.n) * math.Pi * R * R / 0.1)
+
+			db := database.New(database.DefaultO)
+			collider := New(db, O{
+				PoolSize: DefaultO.PoolSize,
+				Sleep:    c.sleep,
+			})
+			defer collider.Close()
+
+			for i := 0; i < c.n; i++ {
+				o := idle(rv(0, max))
+				if float64(i) >= c.idle*float64(c.n) {
+					o.TargetVelocity = rv(-1, 1)
+				}
+				db.InsertAgent(o)
+			}
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				collider.Tick(33 * time.Millisecond)
+			}
+		})
+	}
+}
@@ -0,0 +1,77 @@
+package collider
+
+import (
+	"math"
+
+	"github.com/downflux/go-database/agent"
+)
+
+// Squish is an overlap between an agent and a larger agent which runs over it.
+// See filters.AgentIsSquishable for the conditions under which an agent may be
+// run over, where the agents must share an altitude instead of a terrain layer.
+type Squish struct {
+	// Agent is the agent which was run over, and By is the agent which ran
+	// over Agent.
+	Agent agent.RO
+	By    agent.RO
+
+	// Depth is the overlap depth of the two agents, i.e. the sum of their
+	// radii minus the distance between their centers.
+	Depth float64
+
+	// Speed is the magnitude of the relative velocity of the two agents.
+	Speed float64
+}
+
+// isSquished checks if the input agent a, with the precomputed layer
+// assignment l and altitude h, is being run over by the agent b. The
+// user-supplied filters are applied, as a filtered agent does not physically
+// interact with a.
+func (f filter) isSquished(a agent.RO, l Layers, h Altitude, b agent.RO) bool {
+	if a.ID() == b.ID() || h&f.altitudes.agent(b) == 0 || !isSquishable(a, b) || f.joints.connected(a.ID(), b.ID()) {
+		return false
+	}
+
+	// Agents which are only touching do not overlap.
+	p, q := a.Position(), b.Position()
+	dx, dy := p.X()-q.X(), p.Y()-q.Y()
+	if r := a.Radius() + b.Radius(); dx*dx+dy*dy >= r*r {
+		return false
+	}
+
+	if f.agentLayers != nil && !l.Collides(f.agentLayers(b)) {
+		return false
+	}
+	return f.agent == nil || f.agent(a, b)
+}
+
+// squish records into the worker all agents which are running over the input
+// agent.
+func (c *C) squish(w *worker, a agent.RO) {
+	w.set(a)
+	c.queryAgents(w, w.filterSquish)
+	for _, b := range w.ns {
+		p, q := a.Position(), b.Position()
+		u, v := a.Velocity(), b.Velocity()
+		w.squishes = append(w.squishes, Squish{
+			Agent: a,
+			By:    unwrap(b),
+			Depth: a.Radius() + b.Radius() - math.Hypot(p.X()-q.X(), p.Y()-q.Y()),
+			Speed: math.Hypot(u.X()-v.X(), u.Y()-v.Y()),
+		})
+	}
+}
+
+// squishes sorts squish events by the IDs of the squished agent and then the
+// squishing agent.
+type squishes []Squish
+
+func (s *squishes) Len() int      { return len(*s) }
+func (s *squishes) Swap(i, j int) { (*s)[i], (*s)[j] = (*s)[j], (*s)[i] }
+func (s *squishes) Less(i, j int) bool {
+	a, b := (*s)[i], (*s)[j]
+	if a.Agent.ID() != b.Agent.ID() {
+		return a.Agent.ID() < b.Agent.ID()
+	}
+	return a.By.ID() < b.By.ID()
+}
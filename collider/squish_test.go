@@ -0,0 +1,136 @@
+package collider
+
+import (
+	"testing"
+	"time"
+
+	"github.com/downflux/go-bvh/id"
+	"github.com/downflux/go-database/agent"
+	"github.com/downflux/go-database/database"
+	"github.com/downflux/go-database/flags/size"
+	"github.com/downflux/go-database/flags/team"
+	"github.com/downflux/go-geometry/2d/vector"
+	"github.com/downflux/go-geometry/epsilon"
+)
+
+func TestSquish(t *testing.T) {
+	type event struct {
+		agent int
+		by    int
+		depth float64
+		speed float64
+	}
+
+	type config struct {
+		name string
+		os   []agent.O
+		want []event
+	}
+
+	// vehicle is a large agent of a different team than the default idle
+	// agent, moving at the input velocity.
+	vehicle := func(p vector.V, v vector.V) agent.O {
+		o := idle(p)
+		o.Size = size.FLarge
+		o.Team = team.FNeutral + 1
+		o.Velocity = v
+		return o
+	}
+
+	configs := []config{
+		{
+			name: "Squish",
+			os: []agent.O{
+				idle(vector.V{0, 0}),
+				vehicle(vector.V{0.75, 0}, vector.V{-3, 4}),
+			},
+			want: []event{{agent: 0, by: 1, depth: 0.25, speed: 5}},
+		},
+		{
+			name: "Multiple",
+			os: []agent.O{
+				vehicle(vector.V{0.5, 0}, vector.V{0, 0}),
+				idle(vector.V{1, 0}),
+				idle(vector.V{0, 0}),
+			},
+			want: []event{
+				{agent: 1, by: 0, depth: 0.5, speed: 0},
+				{agent: 2, by: 0, depth: 0.5, speed: 0},
+			},
+		},
+		{
+			name: "Touching",
+			os: []agent.O{
+				idle(vector.V{0, 0}),
+				vehicle(vector.V{1, 0}, vector.V{0, 0}),
+			},
+			want: nil,
+		},
+		{
+			name: "Teammate",
+			os: []agent.O{
+				idle(vector.V{0, 0}),
+				func() agent.O {
+					o := vehicle(vector.V{0.5, 0}, vector.V{0, 0})
+					o.Team = team.FNeutral
+					return o
+				}(),
+			},
+			want: nil,
+		},
+		{
+			name: "SameSize",
+			os: []agent.O{
+				idle(vector.V{0, 0}),
+				func() agent.O {
+					o := vehicle(vector.V{0.5, 0}, vector.V{0, 0})
+					o.Size = size.FSmall
+					return o
+				}(),
+			},
+			want: nil,
+		},
+	}
+
+	for _, c := range configs {
+		t.Run(c.name, func(t *testing.T) {
+			var got []event
+			var xs []id.ID
+
+			db := database.New(database.DefaultO)
+			collider := New(db, O{
+				PoolSize: DefaultO.PoolSize,
+				OnSquish: func(es []Squish) {
+					index := map[id.ID]int{}
+					for i, x := range xs {
+						index[x] = i
+					}
+					for _, e := range es {
+						got = append(got, event{
+							agent: index[e.Agent.ID()],
+							by:    index[e.By.ID()],
+							depth: e.Depth,
+							speed: e.Speed,
+						})
+					}
+				},
+			})
+			defer collider.Close()
+
+			for _, o := range c.os {
+				xs = append(xs, db.InsertAgent(o).ID())
+			}
+
+			collider.Tick(20 * time.Millisecond)
+
+			if len(got) != len(c.want) {
+				t.Fatalf("len() = %v, want = %v", len(got), len(c.want))
+			}
+			for i := range got {
+				if got[i].agent != c.want[i].agent || got[i].by != c.want[i].by || !epsilon.Within(got[i].depth, c.want[i].depth) || !epsilon.Within(got[i].speed, c.want[i].speed) {
+					t.Errorf("Squish() = %v, want = %v", got[i], c.want[i])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,95 @@
+package collider
+
+import (
+	"time"
+)
+
+// Stats contains execution statistics for a single tick.
+type Stats struct {
+	// Total is the wall time of the full tick.
+	Total time.Duration
+
+	// Generate is the wall time of the parallel velocity generation
+	// phase.
+	Generate time.Duration
+
+	// NeighborQuery, FeatureQuery, and Kinematics are the time spent in
+	// each step of the generation phase, summed across all workers. These
+	// values may therefore exceed the wall time of the generation phase.
+	NeighborQuery time.Duration
+	FeatureQuery  time.Duration
+	Kinematics    time.Duration
+
+	// Apply is the wall time of the phase which commits the generated
+	// positions, headings, and velocities into the store.
+	Apply time.Duration
+
+	// Agents is the number of agents whose velocities were generated
+	// during the tick. Sleeping and Deferred are the number of agents which
+	// were skipped because they were asleep or not scheduled for the tick
+	// respectively.
+	Agents      int
+	Sleeping    int
+	Deferred    int
+	Projectiles int
+
+	NeighborQueries int
+	FeatureQueries  int
+
+	// Neighbors and Features are the total number of colliding neighbors
+	// and features returned by the queries across all agents in the tick.
+	Neighbors int
+	Features  int
+
+	// MaxNeighbors is the largest number of colliding neighbors seen by a
+	// single agent.
+	MaxNeighbors int
+
+	// Moved is the number of agents which have a non-zero velocity for
+	// the tick, i.e. whose positions were updated in the store.
+	Moved int
+
+	// Clamped is the number of agents whose non-zero velocity was forced to
+	// zero by the ClampFeatureCollision and ClampNeighborCollision stages,
+	// i.e. agents which were stopped due to a multi-body collision.
+	Clamped int
+}
+
+func (s *Stats) merge(t Stats) {
+	s.NeighborQuery += t.NeighborQuery
+	s.FeatureQuery += t.FeatureQuery
+	s.Kinematics += t.Kinematics
+
+	s.Agents += t.Agents
+	s.Sleeping += t.Sleeping
+	s.Deferred += t.Deferred
+	s.Projectiles += t.Projectiles
+
+	s.NeighborQueries += t.NeighborQueries
+	s.FeatureQueries += t.FeatureQueries
+
+	s.Neighbors += t.Neighbors
+	s.Features += t.Features
+	if t.MaxNeighbors > s.MaxNeighbors {
+		s.MaxNeighbors = t.MaxNeighbors
+	}
+
+	s.Clamped += t.Clamped
+}
+
+// stopwatch measures the time between consecutive laps. A disabled stopwatch
+// does not query the system clock and always reports a zero duration.
+type stopwatch struct {
+	enabled bool
+	last    time.Time
+}
+
+func (w *stopwatch) lap() time.Duration {
+	if !w.enabled {
+		return 0
+	}
+	t := time.Now()
+	d := t.Sub(w.last)
+	w.last = t
+	return d
+}
@@ -0,0 +1,178 @@
+package collider
+
+import (
+	"github.com/downflux/go-database/agent"
+	"github.com/downflux/go-database/database"
+	"github.com/downflux/go-database/feature"
+	"github.com/downflux/go-database/projectile"
+	"github.com/downflux/go-geometry/2d/hyperrectangle"
+	"github.com/downflux/go-geometry/2d/vector"
+	"github.com/downflux/go-geometry/2d/vector/polar"
+)
+
+// Lister lists the bodies which are advanced by the collider.
+//
+// Listed agents must have unique IDs. The collider keys per-agent state (e.g.
+// sleep tracking) by ID.
+type Lister interface {
+	// Agents appends all agents into the input buffer.
+	Agents(buf []agent.RO) []agent.RO
+
+	// Projectiles appends all projectiles into the input buffer.
+	Projectiles(buf []projectile.RO) []projectile.RO
+}
+
+// Querier runs spatial queries against the current positions of the bodies in
+// the store. Query methods are called concurrently, and append all bodies
+// which overlap the query rectangle and pass the filter into the input
+// buffer.
+type Querier interface {
+	QueryAgents(q hyperrectangle.R, filter func(a agent.RO) bool, buf []agent.RO) []agent.RO
+	QueryFeatures(q hyperrectangle.R, filter func(f feature.RO) bool, buf []feature.RO) []feature.RO
+}
+
+// Committer commits the generated results of a tick into the store. The
+// results are only valid for the duration of the call, and must be copied if
+// retained.
+type Committer interface {
+	// SetAgents commits the velocities and headings of the input agents.
+	// SetAgents is called concurrently on disjoint batches of agents.
+	// Skipped agents are unchanged by the tick, and may be ignored.
+	SetAgents(rs []AgentResult)
+
+	// MoveAgents commits the positions of the input agents, and is called
+	// once per tick with all agents, after all calls to SetAgents have
+	// returned. The spatial index of the store should be updated here in
+	// bulk.
+	MoveAgents(rs []AgentResult)
+
+	// SetProjectiles commits the positions, velocities, and headings of
+	// the input projectiles, and is called once per tick.
+	SetProjectiles(rs []ProjectileResult)
+}
+
+// Store is the set of storage operations used by the collider during a tick.
+// Store decouples the tick algorithm from go-database, which is supported via
+// New, and allows callers to run the collider directly against their own
+// storage.
+//
+// Methods which return slices append their results into the input buffer,
+// which allows the collider to reuse buffers across ticks. Unless noted
+// otherwise, methods are called serially. The store must not be mutated
+// during a tick.
+type Store interface {
+	Lister
+	Querier
+	Committer
+}
+
+// AgentResult is the generated state of an agent for a tick.
+type AgentResult struct {
+	Agent    agent.RO
+	Position vector.V
+	Velocity vector.V
+	Heading  polar.V
+
+	// Moved indicates the agent has a non-zero velocity for the tick, and
+	// its position needs to be updated.
+	Moved bool
+
+	// Skipped indicates the agent was not advanced in the tick, e.g. the
+	// agent is asleep, and its state is unchanged.
+	Skipped bool
+}
+
+// ProjectileResult is the generated state of a projectile for a tick.
+type ProjectileResult struct {
+	Projectile projectile.RO
+	Position   vector.V
+	Velocity   vector.V
+	Heading    polar.V
+}
+
+// dbstore adapts a go-database DB into a store.
+//
+// N.B.: Ticks over a dbstore are not allocation-free. The database allocates on
+// every call which the store makes into it, e.g. ListAgents is fed by a
+// goroutine over a channel, QueryAgents returns a new slice, and
+// SetAgentPosition allocates the new AABB of the agent in the BVH. The database
+// API does not accept caller-owned buffers, and so this cannot be fixed in the
+// store. The allocation-free guarantee of the collider therefore only holds for
+// stores passed into NewStore which honor the buffer contract of Store.
+type dbstore database.DB
+
+func (s *dbstore) Agents(buf []agent.RO) []agent.RO {
+	for a := range (*database.DB)(s).ListAgents() {
+		buf = append(buf, a)
+	}
+	return buf
+}
+
+func (s *dbstore) Projectiles(buf []projectile.RO) []projectile.RO {
+	for p := range (*database.DB)(s).ListProjectiles() {
+		buf = append(buf, p)
+	}
+	return buf
+}
+
+func (s *dbstore) QueryAgents(q hyperrectangle.R, filter func(a agent.RO) bool, buf []agent.RO) []agent.RO {
+	return append(buf, (*database.DB)(s).QueryAgents(q, filter)...)
+}
+
+func (s *dbstore) QueryFeatures(q hyperrectangle.R, filter func(f feature.RO) bool, buf []feature.RO) []feature.RO {
+	return append(buf, (*database.DB)(s).QueryFeatures(q, filter)...)
+}
+
+func (s *dbstore) SetAgents(rs []AgentResult) {
+	for _, r := range rs {
+		if r.Skipped {
+			continue
+		}
+		if m, ok := r.Agent.(mutable); ok {
+			m.SetHeading(r.Heading)
+			m.SetVelocity(r.Velocity)
+			continue
+		}
+		(*database.DB)(s).SetAgentHeading(r.Agent.ID(), r.Heading)
+		(*database.DB)(s).SetAgentVelocity(r.Agent.ID(), r.Velocity)
+	}
+}
+
+// MoveAgents updates the positions of all moving agents.
+//
+// N.B.: The database does not support a bulk BVH refit, and concurrent BVH
+// updates are not supported, so agents are updated serially. However, we do
+// skip the BVH update entirely for agents which did not move during the tick.
+// The BVH of the database is not exported, and SetAgentPosition is the only
+// way to update it, so neither a bulk refit nor a rebuild above some fraction
+// of moved agents is possible here without an upstream API. Stores which own
+// their spatial index may refit it in bulk in MoveAgents.
+func (s *dbstore) MoveAgents(rs []AgentResult) {
+	for _, r := range rs {
+		if r.Moved {
+			(*database.DB)(s).SetAgentPosition(r.Agent.ID(), r.Position)
+		}
+	}
+}
+
+func (s *dbstore) DeleteProjectiles(ps []projectile.RO) {
+	for _, p := range ps {
+		(*database.DB)(s).DeleteProjectile(p.ID())
+	}
+}
+
+func (s *dbstore) SetProjectiles(rs []ProjectileResult) {
+	for _, r := range rs {
+		(*database.DB)(s).SetProjectilePosition(r.Projectile.ID(), r.Position)
+		(*database.DB)(s).SetProjectileHeading(r.Projectile.ID(), r.Heading)
+		(*database.DB)(s).SetProjectileVelocity(r.Projectile.ID(), r.Velocity)
+	}
+}
+
+// mutable is the setter interface of the agents stored in the database. Agents
+// which implement mutable are updated directly, which skips the ID lookup of
+// the equivalent database calls.
+type mutable interface {
+	SetHeading(v polar.V)
+	SetVelocity(v vector.V)
+}
@@ -0,0 +1,247 @@
+package collider
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/downflux/go-bvh/id"
+	"github.com/downflux/go-collider/kinematics"
+	"github.com/downflux/go-database/agent"
+	"github.com/downflux/go-database/database"
+	"github.com/downflux/go-database/feature"
+	"github.com/downflux/go-database/flags"
+	"github.com/downflux/go-database/flags/move"
+	"github.com/downflux/go-database/flags/size"
+	"github.com/downflux/go-database/flags/team"
+	"github.com/downflux/go-database/projectile"
+	"github.com/downflux/go-geometry/2d/hyperrectangle"
+	"github.com/downflux/go-geometry/2d/vector"
+	"github.com/downflux/go-geometry/2d/vector/polar"
+
+	mfeature "github.com/downflux/go-database/feature/mock"
+)
+
+var (
+	_ Store    = &memstore{}
+	_ agent.RO = &body{}
+)
+
+// body is a mutable agent which is stored in a memstore.
+type body struct {
+	id       id.ID
+	p        vector.M
+	v        vector.M
+	target   vector.M
+	h        polar.M
+	r        float64
+	maxV     float64
+	maxA     float64
+	maxOmega float64
+}
+
+func (b *body) ID() id.ID                   { return b.id }
+func (b *body) Position() vector.V          { return b.p.V() }
+func (b *body) TargetPosition() vector.V    { return b.p.V() }
+func (b *body) Velocity() vector.V          { return b.v.V() }
+func (b *body) TargetVelocity() vector.V    { return b.target.V() }
+func (b *body) Heading() polar.V            { return b.h.V() }
+func (b *body) Radius() float64             { return b.r }
+func (b *body) Mass() float64               { return 1 }
+func (b *body) MaxVelocity() float64        { return b.maxV }
+func (b *body) MaxAngularVelocity() float64 { return b.maxOmega }
+func (b *body) MaxAcceleration() float64    { return b.maxA }
+func (b *body) Flags() flags.F              { return flags.FNone }
+func (b *body) Size() size.F                { return size.FSmall }
+func (b *body) Team() team.F                { return team.FNeutral }
+func (b *body) MoveMode() move.F            { return move.FNone }
+func (b *body) AABB() hyperrectangle.R {
+	return *hyperrectangle.New(
+		vector.V{b.p.X() - b.r, b.p.Y() - b.r},
+		vector.V{b.p.X() + b.r, b.p.Y() + b.r},
+	)
+}
+
+// memstore is a slice-backed store which does not allocate, and is used to
+// verify the collider itself does not allocate during a tick.
+type memstore struct {
+	bodies   []*body
+	features []feature.RO
+}
+
+func (s *memstore) Agents(buf []agent.RO) []agent.RO {
+	for _, b := range s.bodies {
+		buf = append(buf, b)
+	}
+	return buf
+}
+
+func (s *memstore) Projectiles(buf []projectile.RO) []projectile.RO { return buf }
+
+func (s *memstore) QueryAgents(q hyperrectangle.R, filter func(a agent.RO) bool, buf []agent.RO) []agent.RO {
+	for _, b := range s.bodies {
+		p := b.p
+		if p.X()+b.r < q.Min().X() || p.X()-b.r > q.Max().X() || p.Y()+b.r < q.Min().Y() || p.Y()-b.r > q.Max().Y() {
+			continue
+		}
+		if filter(b) {
+			buf = append(buf, b)
+		}
+	}
+	return buf
+}
+
+func (s *memstore) QueryFeatures(q hyperrectangle.R, filter func(f feature.RO) bool, buf []feature.RO) []feature.RO {
+	for _, f := range s.features {
+		if !hyperrectangle.Disjoint(q, f.AABB()) && filter(f) {
+			buf = append(buf, f)
+		}
+	}
+	return buf
+}
+
+func (s *memstore) SetAgents(rs []AgentResult) {
+	for _, r := range rs {
+		b := s.bodies[r.Agent.ID()]
+		b.v.Copy(r.Velocity)
+		b.h.Copy(r.Heading)
+	}
+}
+
+func (s *memstore) MoveAgents(rs []AgentResult) {
+	for _, r := range rs {
+		s.bodies[r.Agent.ID()].p.Copy(r.Position)
+	}
+}
+
+func (s *memstore) SetProjectiles(rs []ProjectileResult) {}
+
+func TestTickAllocs(t *testing.T) {
+	// push is the velocity of all features in the Motion config.
+	push := vector.V{1, 0}
+
+	// store generates a dense grid of agents moving into a feature and
+	// one another. Each config runs over a fresh store, as the agents are
+	// advanced by the tick.
+	store := func() *memstore {
+		s := &memstore{
+			features: []feature.RO{
+				mfeature.New(0, feature.O{
+					AABB: *hyperrectangle.New(vector.V{-1, -1}, vector.V{0, 21}),
+				}),
+			},
+		}
+		for i := 0; i < 20; i++ {
+			for j := 0; j < 20; j++ {
+				s.bodies = append(s.bodies, &body{
+					id:       id.ID(len(s.bodies)),
+					p:        vector.M{float64(i) + R, float64(j) + R},
+					v:        vector.M{0, 0},
+					target:   vector.M{-1, float64(i%3 - 1)},
+					h:        polar.M{1, math.Pi},
+					r:        R,
+					maxV:     10,
+					maxA:     5,
+					maxOmega: math.Pi,
+				})
+			}
+		}
+		return s
+	}
+
+	type config struct {
+		name string
+		o    O
+	}
+
+	configs := []config{
+		{name: "Default", o: DefaultO},
+		{name: "OnStats", o: O{PoolSize: DefaultO.PoolSize, OnStats: func(s Stats) {}}},
+		{name: "Grid", o: O{PoolSize: DefaultO.PoolSize, Broadphase: NewGrid(2 * R)}},
+		{name: "Pipeline", o: O{PoolSize: DefaultO.PoolSize, Pipeline: append([]Stage{slow}, DefaultPipeline...)}},
+		{name: "Filter", o: O{PoolSize: DefaultO.PoolSize, AgentLayers: func(a agent.RO) Layers { return DefaultLayers }, FilterAgent: func(a agent.RO, b agent.RO) bool { return true }}},
+		{name: "OnSquish", o: O{PoolSize: DefaultO.PoolSize, OnSquish: func(es []Squish) {}}},
+		{name: "Jam", o: O{PoolSize: DefaultO.PoolSize, JamTicks: 1, JamStrategy: JamYield, OnJam: func(as []agent.RO) {}}},
+		{name: "Priority", o: O{PoolSize: DefaultO.PoolSize, Priority: func(a agent.RO) int { return int(a.ID() % 2) }, Nudge: true}},
+		{name: "Altitude", o: O{PoolSize: DefaultO.PoolSize, FeatureAltitude: func(f feature.RO) Altitude { return AltitudeGround }, OnAltitude: func(a agent.RO, k Altitude) {}}},
+		{name: "Wrap", o: O{PoolSize: DefaultO.PoolSize, Wrap: hyperrectangle.New(vector.V{-1, -1}, vector.V{20, 20}), OnSquish: func(es []Squish) {}}},
+		{name: "Bounds", o: O{PoolSize: DefaultO.PoolSize, Bounds: hyperrectangle.New(vector.V{-1, -1}, vector.V{20, 20})}},
+		{name: "Motion", o: O{PoolSize: DefaultO.PoolSize, Sleep: true, FeatureMotion: func(f feature.RO) Motion { return Motion{Velocity: push} }}},
+		{name: "Trigger", o: O{PoolSize: DefaultO.PoolSize, Sensor: func(f feature.RO) bool { return true }, OnTrigger: func(ts []Trigger) {}}},
+		{name: "Shape", o: O{PoolSize: DefaultO.PoolSize, Shape: func(a agent.RO) kinematics.Shape { return kinematics.Shape{Length: R / 2, Radius: R / 2} }}},
+	}
+
+	for _, c := range configs {
+		t.Run(c.name, func(t *testing.T) {
+			collider := NewStore(store(), c.o)
+			defer collider.Close()
+
+			// Warm up the collider buffers.
+			for i := 0; i < 3; i++ {
+				collider.Tick(20 * time.Millisecond)
+			}
+
+			if got := testing.AllocsPerRun(20, func() { collider.Tick(20 * time.Millisecond) }); got != 0 {
+				t.Errorf("AllocsPerRun() = %v, want = 0", got)
+			}
+		})
+	}
+}
+
+// TestStoreConsistent verifies a collider over an external store generates the
+// same results as a collider over the equivalent database.
+func TestStoreConsistent(t *testing.T) {
+	db := database.New(database.DefaultO)
+	s := &memstore{}
+
+	for i := 0; i < 20; i++ {
+		for j := 0; j < 20; j++ {
+			p := vector.V{1.5 * float64(i), 1.5 * float64(j)}
+			v := vector.V{float64(j%3 - 1), float64(i%3 - 1)}
+			db.InsertAgent(agent.O{
+				Position:           p,
+				TargetPosition:     p,
+				Velocity:           vector.V{0, 0},
+				TargetVelocity:     v,
+				Heading:            polar.V{1, math.Pi},
+				Radius:             R,
+				Mass:               1,
+				MaxVelocity:        10,
+				MaxAcceleration:    5,
+				MaxAngularVelocity: math.Pi,
+				Size:               size.FSmall,
+			})
+			s.bodies = append(s.bodies, &body{
+				id:       id.ID(len(s.bodies)),
+				p:        vector.M{p.X(), p.Y()},
+				v:        vector.M{0, 0},
+				target:   vector.M{v.X(), v.Y()},
+				h:        polar.M{1, math.Pi},
+				r:        R,
+				maxV:     10,
+				maxA:     5,
+				maxOmega: math.Pi,
+			})
+		}
+	}
+
+	for _, c := range []*C{New(db, DefaultO), NewStore(s, DefaultO)} {
+		for i := 0; i < 50; i++ {
+			c.Tick(20 * time.Millisecond)
+		}
+		c.Close()
+	}
+
+	for _, b := range s.bodies {
+		a := db.GetAgentOrDie(b.ID())
+		if got, want := b.Position(), a.Position(); !vector.Within(got, want) {
+			t.Errorf("Position() = %v, want = %v", got, want)
+		}
+		if got, want := b.Velocity(), a.Velocity(); !vector.Within(got, want) {
+			t.Errorf("Velocity() = %v, want = %v", got, want)
+		}
+		if got, want := b.Heading(), a.Heading(); !polar.Within(got, want) {
+			t.Errorf("Heading() = %v, want = %v", got, want)
+		}
+	}
+}
@@ -0,0 +1,230 @@
+package collider
+
+import (
+	"math"
+	"sort"
+
+	"github.com/downflux/go-bvh/id"
+	"github.com/downflux/go-database/agent"
+	"github.com/downflux/go-database/feature"
+	"github.com/downflux/go-geometry/2d/hyperrectangle"
+	"github.com/downflux/go-geometry/2d/vector"
+)
+
+// Trigger is an agent entering or leaving a sensor feature, e.g. a capture
+// point. See O.Sensor for more information.
+type Trigger struct {
+	Agent   agent.RO
+	Feature feature.RO
+
+	// Enter is true if the agent entered the sensor during the tick, and
+	// false if the agent left the sensor.
+	Enter bool
+}
+
+// occupancy is an agent which overlaps a sensor.
+type occupancy struct {
+	a agent.RO
+	f feature.RO
+}
+
+type pair struct {
+	a id.ID
+	f id.ID
+}
+
+// sensors tracks the agents inside each sensor across ticks.
+//
+// An agent is inside a sensor if the agent overlaps the sensor at the end of
+// the tick. Agents which are only touching a sensor are not inside the sensor.
+// The path of the agent over the tick is also checked, so that a fast agent
+// which passes through a thin sensor within a single tick is not missed.
+type sensors struct {
+	// inside is the set of agents inside each sensor as of the last tick,
+	// and next is the set of agents inside each sensor in the current tick.
+	// The two sets are swapped at the end of the tick.
+	inside map[pair]occupancy
+	next   map[pair]occupancy
+
+	// crossed is the set of agents which passed through each sensor in the
+	// current tick.
+	crossed map[pair]occupancy
+
+	// triggers is the per-tick buffer of events.
+	triggers triggers
+}
+
+func newSensors() *sensors {
+	return &sensors{
+		inside:  map[pair]occupancy{},
+		next:    map[pair]occupancy{},
+		crossed: map[pair]occupancy{},
+	}
+}
+
+// isSensing checks if the agent, with the AABB aabb and the altitude h, may be
+// inside the feature f with the altitude k.
+func isSensing(h Altitude, aabb hyperrectangle.R, f feature.RO, k Altitude) bool {
+	return h&k != 0 && !hyperrectangle.Disjoint(aabb, f.AABB())
+}
+
+// sense records into the worker all sensors which the input agent overlaps
+// at the input position q, i.e. the unwrapped position of the agent at the end
+// of the tick, and all sensors which the agent passes through while moving from
+// the input position p to q.
+func (c *C) sense(w *worker, a agent.RO, p vector.V, q vector.V) {
+	w.set(a)
+
+	r := a.Radius()
+	min, max := w.aabb.M().Min(), w.aabb.M().Max()
+	min.SetX(math.Min(p.X(), q.X()) - r)
+	min.SetY(math.Min(p.Y(), q.Y()) - r)
+	max.SetX(math.Max(p.X(), q.X()) + r)
+	max.SetY(math.Max(p.Y(), q.Y()) + r)
+
+	c.queryFeatures(w, w.filterSensor)
+	for _, f := range w.fs {
+		g := f.AABB()
+		if isOverlapping(q, r, g) {
+			w.sensed = append(w.sensed, occupancy{a: a, f: unwrapFeature(f)})
+		} else if isCrossing(p, q, r, g) {
+			w.crossed = append(w.crossed, occupancy{a: a, f: unwrapFeature(f)})
+		}
+	}
+}
+
+// isOverlapping checks if the circle with center p and radius r overlaps the
+// input rectangle. Circles which are only touching the rectangle do not
+// overlap.
+func isOverlapping(p vector.V, r float64, g hyperrectangle.R) bool {
+	// (x, y) is the point of the rectangle which is closest to p.
+	x := math.Max(g.Min().X(), math.Min(p.X(), g.Max().X()))
+	y := math.Max(g.Min().Y(), math.Min(p.Y(), g.Max().Y()))
+	dx, dy := p.X()-x, p.Y()-y
+	return dx*dx+dy*dy < r*r
+}
+
+// isCrossing checks if a circle of radius r overlaps the input rectangle at any
+// point while moving from p to q, i.e. if the segment pq intersects the
+// rectangle inflated by r with rounded corners.
+func isCrossing(p vector.V, q vector.V, r float64, g hyperrectangle.R) bool {
+	x0, y0, x1, y1 := g.Min().X(), g.Min().Y(), g.Max().X(), g.Max().Y()
+	if isSegmentInRect(p, q, x0-r, y0, x1+r, y1) || isSegmentInRect(p, q, x0, y0-r, x1, y1+r) {
+		return true
+	}
+	for _, c := range [4][2]float64{{x0, y0}, {x0, y1}, {x1, y0}, {x1, y1}} {
+		if isSegmentInCircle(p, q, c[0], c[1], r) {
+			return true
+		}
+	}
+	return false
+}
+
+// isSegmentInRect checks if the segment pq intersects the interior of the input
+// rectangle.
+func isSegmentInRect(p vector.V, q vector.V, x0, y0, x1, y1 float64) bool {
+	t0, t1 := 0.0, 1.0
+	for _, s := range [2][4]float64{
+		{p.X(), q.X() - p.X(), x0, x1},
+		{p.Y(), q.Y() - p.Y(), y0, y1},
+	} {
+		o, d, lo, hi := s[0], s[1], s[2], s[3]
+		if d == 0 {
+			if o <= lo || o >= hi {
+				return false
+			}
+			continue
+		}
+		a, b := (lo-o)/d, (hi-o)/d
+		if a > b {
+			a, b = b, a
+		}
+		t0, t1 = math.Max(t0, a), math.Min(t1, b)
+		if t0 >= t1 {
+			return false
+		}
+	}
+	return true
+}
+
+// isSegmentInCircle checks if the segment pq intersects the interior of the
+// circle with center (x, y) and radius r.
+func isSegmentInCircle(p vector.V, q vector.V, x, y, r float64) bool {
+	dx, dy := q.X()-p.X(), q.Y()-p.Y()
+	t := 0.0
+	if l := dx*dx + dy*dy; l > 0 {
+		t = math.Max(0, math.Min(1, ((x-p.X())*dx+(y-p.Y())*dy)/l))
+	}
+	ex, ey := p.X()+t*dx-x, p.Y()+t*dy-y
+	return ex*ex+ey*ey < r*r
+}
+
+// update returns the agents which entered or left a sensor during the tick,
+// sorted by agent ID, then sensor ID, with entries before exits. update is
+// called serially once the tick has been committed. Agents which passed through
+// a sensor within the tick are reported as having both entered and left the
+// sensor. Agents which were deleted from the store are reported as having left
+// their sensors. The returned slice is reused across ticks.
+func (s *sensors) update(workers []*worker) []Trigger {
+	for k := range s.next {
+		delete(s.next, k)
+	}
+	for k := range s.crossed {
+		delete(s.crossed, k)
+	}
+	for _, w := range workers {
+		for _, o := range w.sensed {
+			s.next[pair{a: o.a.ID(), f: o.f.ID()}] = o
+		}
+		for _, o := range w.crossed {
+			s.crossed[pair{a: o.a.ID(), f: o.f.ID()}] = o
+		}
+	}
+
+	for i := range s.triggers {
+		s.triggers[i] = Trigger{}
+	}
+	s.triggers = s.triggers[:0]
+	for k, o := range s.next {
+		if _, ok := s.inside[k]; !ok {
+			s.triggers = append(s.triggers, Trigger{Agent: o.a, Feature: o.f, Enter: true})
+		}
+	}
+	for k, o := range s.inside {
+		if _, ok := s.next[k]; !ok {
+			s.triggers = append(s.triggers, Trigger{Agent: o.a, Feature: o.f, Enter: false})
+		}
+	}
+	for k, o := range s.crossed {
+		_, inside := s.inside[k]
+		_, next := s.next[k]
+		if !inside && !next {
+			s.triggers = append(s.triggers,
+				Trigger{Agent: o.a, Feature: o.f, Enter: true},
+				Trigger{Agent: o.a, Feature: o.f, Enter: false},
+			)
+		}
+	}
+	s.inside, s.next = s.next, s.inside
+
+	sort.Sort(&s.triggers)
+	return s.triggers
+}
+
+// triggers sorts trigger events by the IDs of the agent and then the sensor.
+// An agent which passed through a sensor within a single tick enters the
+// sensor before leaving it.
+type triggers []Trigger
+
+func (t *triggers) Len() int      { return len(*t) }
+func (t *triggers) Swap(i, j int) { (*t)[i], (*t)[j] = (*t)[j], (*t)[i] }
+func (t *triggers) Less(i, j int) bool {
+	a, b := (*t)[i], (*t)[j]
+	if a.Agent.ID() != b.Agent.ID() {
+		return a.Agent.ID() < b.Agent.ID()
+	}
+	if a.Feature.ID() != b.Feature.ID() {
+		return a.Feature.ID() < b.Feature.ID()
+	}
+	return a.Enter && !b.Enter
+}
@@ -0,0 +1,164 @@
+package collider
+
+import (
+	"testing"
+	"time"
+
+	"github.com/downflux/go-bvh/id"
+	"github.com/downflux/go-database/agent"
+	"github.com/downflux/go-database/database"
+	"github.com/downflux/go-database/feature"
+	"github.com/downflux/go-geometry/2d/hyperrectangle"
+	"github.com/downflux/go-geometry/2d/vector"
+	"github.com/downflux/go-geometry/2d/vector/polar"
+)
+
+func TestTrigger(t *testing.T) {
+	// event is a trigger event, where a is the index of the agent in the
+	// input agent list.
+	type event struct {
+		a     int
+		enter bool
+	}
+
+	type step struct {
+		// f is an optional mutation to the database before the tick.
+		f    func(db *database.DB, xs []id.ID)
+		want []event
+	}
+
+	type config struct {
+		name  string
+		os    []agent.O
+		steps []step
+	}
+
+	// moving returns an agent which moves along the x-axis by 1 every
+	// tick.
+	moving := func(p vector.V) agent.O {
+		o := idle(p)
+		o.TargetVelocity = vector.V{10, 0}
+		o.Velocity = vector.V{10, 0}
+		o.Heading = polar.V{1, 0}
+		return o
+	}
+
+	// fast returns an agent which moves along the x-axis by 6 every tick,
+	// i.e. past the sensor within a single tick.
+	fast := func(p vector.V) agent.O {
+		o := moving(p)
+		o.TargetVelocity = vector.V{60, 0}
+		o.Velocity = vector.V{60, 0}
+		o.MaxVelocity = 60
+		return o
+	}
+
+	configs := []config{
+		{
+			// The agent passes through the sensor without being
+			// blocked, and leaves the sensor on the far side.
+			name: "Pass",
+			os:   []agent.O{moving(vector.V{0, 5})},
+			steps: []step{
+				{want: nil},
+				{want: []event{{a: 0, enter: true}}},
+				{want: nil},
+				{want: nil},
+				{want: []event{{a: 0, enter: false}}},
+				{want: nil},
+			},
+		},
+		{
+			// The agent passes through the sensor within a single
+			// tick, and both enters and leaves the sensor in that
+			// tick.
+			name: "Tunnel",
+			os:   []agent.O{fast(vector.V{0, 5})},
+			steps: []step{
+				{want: []event{{a: 0, enter: true}, {a: 0, enter: false}}},
+				{want: nil},
+			},
+		},
+		{
+			name: "Order",
+			os: []agent.O{
+				idle(vector.V{3, 5}),
+				moving(vector.V{1, 6}),
+				idle(vector.V{3, 4}),
+			},
+			steps: []step{
+				{want: []event{{a: 0, enter: true}, {a: 1, enter: true}, {a: 2, enter: true}}},
+			},
+		},
+		{
+			// Touching a sensor does not trigger the sensor.
+			name: "Touching",
+			os:   []agent.O{idle(vector.V{1.5, 5})},
+			steps: []step{
+				{want: nil},
+			},
+		},
+		{
+			name: "Delete",
+			os:   []agent.O{idle(vector.V{3, 5}), idle(vector.V{3, 7})},
+			steps: []step{
+				{want: []event{{a: 0, enter: true}, {a: 1, enter: true}}},
+				{
+					f: func(db *database.DB, xs []id.ID) {
+						db.DeleteAgent(xs[1])
+					},
+					want: []event{{a: 1, enter: false}},
+				},
+			},
+		},
+	}
+
+	for _, c := range configs {
+		t.Run(c.name, func(t *testing.T) {
+			var got []event
+
+			db := database.New(database.DefaultO)
+
+			var xs []id.ID
+			index := map[id.ID]int{}
+			for i, o := range c.os {
+				x := db.InsertAgent(o).ID()
+				xs = append(xs, x)
+				index[x] = i
+			}
+			sensor := db.InsertFeature(feature.O{
+				AABB: *hyperrectangle.New(vector.V{2, 0}, vector.V{4, 10}),
+			})
+
+			collider := New(db, O{
+				PoolSize: DefaultO.PoolSize,
+				Sensor:   func(f feature.RO) bool { return f.ID() == sensor.ID() },
+				OnTrigger: func(ts []Trigger) {
+					for _, e := range ts {
+						if e.Feature.ID() != sensor.ID() {
+							t.Errorf("ID() = %v, want = %v", e.Feature.ID(), sensor.ID())
+						}
+						got = append(got, event{a: index[e.Agent.ID()], enter: e.Enter})
+					}
+				},
+			})
+			defer collider.Close()
+
+			for i, s := range c.steps {
+				if s.f != nil {
+					s.f(db, xs)
+				}
+				got = nil
+				collider.Tick(100 * time.Millisecond)
+				if len(got) != len(s.want) {
+					t.Fatalf("[%v]: OnTrigger() = %v, want = %v", i, got, s.want)
+				}
+				for j := range s.want {
+					if got[j] != s.want[j] {
+						t.Errorf("[%v]: OnTrigger()[%v] = %v, want = %v", i, j, got[j], s.want[j])
+					}
+				}
+			}
+		})
+	}
+}
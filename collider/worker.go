@@ -0,0 +1,246 @@
+package collider
+
+import (
+	"math"
+
+	"github.com/downflux/go-bvh/id"
+	"github.com/downflux/go-database/agent"
+	"github.com/downflux/go-database/feature"
+	"github.com/downflux/go-geometry/2d/hyperrectangle"
+	"github.com/downflux/go-geometry/2d/vector"
+	"github.com/downflux/go-geometry/2d/vector/polar"
+	"github.com/downflux/go-geometry/epsilon"
+)
+
+// worker contains the buffers used by a single worker goroutine during
+// velocity generation. These buffers are reused between ticks.
+type worker struct {
+	stats Stats
+	w     stopwatch
+
+	// a is the agent currently being processed by the worker, and aabb is
+	// the AABB of the agent.
+	a    agent.RO
+	aabb hyperrectangle.R
+
+	ns []agent.RO
+	fs []feature.RO
+
+	// state is the pipeline state of the current agent.
+	state State
+
+	// naps and contacts record the agents which will fall asleep at the
+	// end of the tick. See sleep for more information.
+	naps     []nap
+	contacts []id.ID
+
+	// layers and altitude are the layer assignment and altitude of the
+	// current agent.
+	layers   Layers
+	altitude Altitude
+
+	// filter is the set of user-supplied collision filters of the
+	// collider.
+	filter filter
+
+	// squishes records the squish events generated by the worker.
+	squishes []Squish
+
+	// filterAgent and filterFeature check for collisions against the
+	// current agent. The filters are bound to the worker once in order to
+	// avoid allocating a new closure for every query.
+	filterAgent   func(b agent.RO) bool
+	filterFeature func(f feature.RO) bool
+
+	// filterCarrier checks if the current agent stands on a platform, and
+	// filterSensor checks if the current agent may be inside a sensor.
+	filterCarrier func(f feature.RO) bool
+	filterSensor  func(f feature.RO) bool
+
+	// sensed records the sensors which the agents of the worker are inside
+	// at the end of the tick, and crossed records the sensors which the
+	// agents passed through during the tick.
+	sensed  []occupancy
+	crossed []occupancy
+
+	// filterSquish checks if the current agent is being run over.
+	filterSquish func(b agent.RO) bool
+
+	// self, offsets, images, and fimages are the buffers used by queries
+	// across the edges of a wrapped world. The images are reused across
+	// agents, and only valid while processing the current agent.
+	self     image
+	offsets  [8][2]float64
+	images   []*image
+	fimages  []*featureImage
+	nimages  int
+	nfimages int
+}
+
+func newWorker(f filter) *worker {
+	w := &worker{
+		filter: f,
+		aabb:   *hyperrectangle.New(vector.V{0, 0}, vector.V{0, 0}),
+		ns:     make([]agent.RO, 0, 16),
+		fs:     make([]feature.RO, 0, 16),
+		self:   *newImage(),
+	}
+	w.filterAgent = func(b agent.RO) bool { return w.filter.isColliding(w.a, w.layers, w.altitude, b) }
+	w.filterFeature = func(f feature.RO) bool { return w.filter.isCollidingWithFeature(w.a, w.aabb, w.layers, w.altitude, f) }
+	w.filterCarrier = func(f feature.RO) bool { return w.filter.isCarrying(w.a, w.altitude, f) }
+	w.filterSensor = func(f feature.RO) bool { return w.filter.isSensing(w.aabb, w.altitude, f) }
+	w.filterSquish = func(b agent.RO) bool { return w.filter.isSquished(w.a, w.layers, w.altitude, b) }
+	return w
+}
+
+// set sets the current agent of the worker.
+func (w *worker) set(a agent.RO) {
+	w.a = a
+	w.layers = w.filter.layers(a)
+	w.altitude = w.filter.altitudes.agent(a)
+	w.nimages, w.nfimages = 0, 0
+
+	p, r := a.Position(), a.Radius()
+	min, max := w.aabb.M().Min(), w.aabb.M().Max()
+	min.SetX(p.X() - r)
+	min.SetY(p.Y() - r)
+	max.SetX(p.X() + r)
+	max.SetY(p.Y() + r)
+}
+
+// image returns an image of the input agent shifted by (x, y).
+func (w *worker) image(b agent.RO, x float64, y float64) agent.RO {
+	if w.nimages == len(w.images) {
+		w.images = append(w.images, newImage())
+	}
+	a := w.images[w.nimages]
+	w.nimages++
+	a.set(b, x, y)
+	return a
+}
+
+// featureImage returns an image of the input feature shifted by (x, y).
+func (w *worker) featureImage(g feature.RO, x float64, y float64) feature.RO {
+	if w.nfimages == len(w.fimages) {
+		w.fimages = append(w.fimages, newFeatureImage())
+	}
+	f := w.fimages[w.nfimages]
+	w.nfimages++
+	f.set(g, x, y)
+	return f
+}
+
+// reserve grows the buffers of all workers before the agents are generated.
+// Agents are spread across the workers by work stealing, and so the share of
+// the agents which each worker generates changes between ticks. Buffers which
+// are reused per agent are grown to the largest buffer of any worker, and
+// buffers which accumulate across agents are grown to the total length of the
+// buffer across all workers in the previous tick, so that the buffers only grow
+// if the amount of work grows, and not due to scheduling. reserve is called
+// serially before generation.
+func (c *C) reserve() {
+	var ns, fs, images, fimages int
+	var naps, contacts, squishes, sensed, crossed int
+	for _, w := range c.workers {
+		if cap(w.ns) > ns {
+			ns = cap(w.ns)
+		}
+		if cap(w.fs) > fs {
+			fs = cap(w.fs)
+		}
+		if len(w.images) > images {
+			images = len(w.images)
+		}
+		if len(w.fimages) > fimages {
+			fimages = len(w.fimages)
+		}
+		naps += len(w.naps)
+		contacts += len(w.contacts)
+		squishes += len(w.squishes)
+		sensed += len(w.sensed)
+		crossed += len(w.crossed)
+	}
+
+	for _, w := range c.workers {
+		if cap(w.ns) < ns {
+			w.ns = make([]agent.RO, 0, ns)
+		}
+		if cap(w.fs) < fs {
+			w.fs = make([]feature.RO, 0, fs)
+		}
+		for len(w.images) < images {
+			w.images = append(w.images, newImage())
+		}
+		for len(w.fimages) < fimages {
+			w.fimages = append(w.fimages, newFeatureImage())
+		}
+		if cap(w.naps) < naps {
+			w.naps = make([]nap, 0, naps)
+		}
+		if cap(w.contacts) < contacts {
+			w.contacts = make([]id.ID, 0, contacts)
+		}
+		if cap(w.squishes) < squishes {
+			w.squishes = make([]Squish, 0, squishes)
+		}
+		if cap(w.sensed) < sensed {
+			w.sensed = make([]occupancy, 0, sensed)
+		}
+		if cap(w.crossed) < crossed {
+			w.crossed = make([]occupancy, 0, crossed)
+		}
+	}
+}
+
+// sortAgents sorts the input agents by position, and then by ID. Sorting by
+// position ensures the order does not depend on how IDs were assigned, e.g.
+// when the same agent is mirrored into several partitioned databases. The
+// number of neighbors of an agent is generally small, so we use an insertion
+// sort here, which also does not allocate, unlike sort.Slice.
+func sortAgents(ns []agent.RO) {
+	for i := 1; i < len(ns); i++ {
+		for j := i; j > 0 && less(ns[j], ns[j-1]); j-- {
+			ns[j], ns[j-1] = ns[j-1], ns[j]
+		}
+	}
+}
+
+func less(a agent.RO, b agent.RO) bool {
+	p, q := a.Position(), b.Position()
+	if p.X() != q.X() {
+		return p.X() < q.X()
+	}
+	if p.Y() != q.Y() {
+		return p.Y() < q.Y()
+	}
+	return a.ID() < b.ID()
+}
+
+// sortFeatures sorts the input features by ID. See sortAgents for more
+// information.
+func sortFeatures(fs []feature.RO) {
+	for i := 1; i < len(fs); i++ {
+		for j := i; j > 0 && fs[j].ID() < fs[j-1].ID(); j-- {
+			fs[j], fs[j-1] = fs[j-1], fs[j]
+		}
+	}
+}
+
+func isZero(v vector.V) bool {
+	return epsilon.Within(v.X(), 0) && epsilon.Within(v.Y(), 0)
+}
+
+// heading sets the input polar buffer to the unit heading of the input
+// velocity. This is equivalent to
+//
+//	polar.Polar(vector.Unit(v))
+//
+// but does not allocate.
+func heading(v vector.V, h polar.M) {
+	k := 1 / math.Sqrt(v.X()*v.X()+v.Y()*v.Y())
+	x, y := v.X()*k, v.Y()*k
+
+	h.SetR(math.Sqrt(x*x + y*y))
+	h.SetTheta(math.Atan2(y, x))
+	h.Normalize()
+}
@@ -0,0 +1,233 @@
+package collider
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/downflux/go-database/agent"
+	"github.com/downflux/go-database/feature"
+	"github.com/downflux/go-geometry/2d/hyperrectangle"
+	"github.com/downflux/go-geometry/2d/vector"
+)
+
+// torus is a wrap-around world, where bodies which leave one edge of the world
+// reappear on the opposite edge.
+//
+// Bodies near an edge of the world interact with the bodies near the opposite
+// edge through images, i.e. proxies of the bodies which are shifted by the
+// size of the world, so that the collision normals between a body and its
+// neighbors are computed over the wrapped distance.
+type torus struct {
+	x0, y0 float64
+	x1, y1 float64
+
+	// margin is the largest agent radius in the current tick. An agent
+	// within the margin of an edge may be in contact with an agent across
+	// the edge.
+	margin float64
+}
+
+func newTorus(r hyperrectangle.R) *torus {
+	min, max := r.Min(), r.Max()
+	if !(min.X() < max.X() && min.Y() < max.Y()) {
+		panic(fmt.Sprintf("wrap bounds specified %v are empty", r))
+	}
+	return &torus{
+		x0: min.X(),
+		y0: min.Y(),
+		x1: max.X(),
+		y1: max.Y(),
+	}
+}
+
+// update records the largest agent radius of the current tick. update is
+// called serially before velocity generation.
+func (t *torus) update(agents []agent.RO) {
+	t.margin = 0
+	for _, a := range agents {
+		t.margin = math.Max(t.margin, a.Radius())
+	}
+}
+
+// wrap moves the input position buffer into the world.
+func (t *torus) wrap(p vector.M) {
+	p.SetX(wrap(p.X(), t.x0, t.x1))
+	p.SetY(wrap(p.Y(), t.y0, t.y1))
+}
+
+func wrap(x float64, min float64, max float64) float64 {
+	if x >= min && x < max {
+		return x
+	}
+	x = math.Mod(x-min, max-min)
+	if x < 0 {
+		x += max - min
+	}
+	return min + x
+}
+
+// offsets returns the shifts which map the input query rectangle onto the
+// opposite edges of the world. The returned slice is backed by the input
+// buffer.
+func (t *torus) offsets(r hyperrectangle.R, buf *[8][2]float64) [][2]float64 {
+	w, h := t.x1-t.x0, t.y1-t.y0
+	min, max := r.Min(), r.Max()
+
+	var xs, ys [3]float64
+	nx, ny := 1, 1
+	if min.X()-t.margin < t.x0 {
+		xs[nx], nx = w, nx+1
+	}
+	if max.X()+t.margin > t.x1 {
+		xs[nx], nx = -w, nx+1
+	}
+	if min.Y()-t.margin < t.y0 {
+		ys[ny], ny = h, ny+1
+	}
+	if max.Y()+t.margin > t.y1 {
+		ys[ny], ny = -h, ny+1
+	}
+
+	n := 0
+	for i := 0; i < nx; i++ {
+		for j := 0; j < ny; j++ {
+			if i == 0 && j == 0 {
+				continue
+			}
+			buf[n] = [2]float64{xs[i], ys[j]}
+			n++
+		}
+	}
+	return buf[:n]
+}
+
+// image is an agent which is shifted by the size of the world.
+type image struct {
+	agent.RO
+
+	p    vector.V
+	aabb hyperrectangle.R
+}
+
+func newImage() *image {
+	return &image{
+		p:    vector.V{0, 0},
+		aabb: *hyperrectangle.New(vector.V{0, 0}, vector.V{0, 0}),
+	}
+}
+
+func (a *image) Position() vector.V     { return a.p }
+func (a *image) AABB() hyperrectangle.R { return a.aabb }
+
+// set sets the image to the input agent shifted by (x, y).
+func (a *image) set(b agent.RO, x float64, y float64) {
+	a.RO = b
+	p, r := b.Position(), b.Radius()
+	a.p.M().SetX(p.X() + x)
+	a.p.M().SetY(p.Y() + y)
+
+	min, max := a.aabb.M().Min(), a.aabb.M().Max()
+	min.SetX(a.p.X() - r)
+	min.SetY(a.p.Y() - r)
+	max.SetX(a.p.X() + r)
+	max.SetY(a.p.Y() + r)
+}
+
+// featureImage is a feature which is shifted by the size of the world.
+type featureImage struct {
+	feature.RO
+
+	aabb hyperrectangle.R
+}
+
+func newFeatureImage() *featureImage {
+	return &featureImage{
+		aabb: *hyperrectangle.New(vector.V{0, 0}, vector.V{0, 0}),
+	}
+}
+
+func (f *featureImage) AABB() hyperrectangle.R { return f.aabb }
+
+func (f *featureImage) set(g feature.RO, x float64, y float64) {
+	f.RO = g
+	shift(g.AABB(), x, y, f.aabb)
+}
+
+// shift sets the input buffer to the rectangle r shifted by (x, y).
+func shift(r hyperrectangle.R, x float64, y float64, buf hyperrectangle.R) {
+	min, max := buf.M().Min(), buf.M().Max()
+	min.SetX(r.Min().X() + x)
+	min.SetY(r.Min().Y() + y)
+	max.SetX(r.Max().X() + x)
+	max.SetY(r.Max().Y() + y)
+}
+
+// unwrap returns the underlying agent of a possible image.
+func unwrap(a agent.RO) agent.RO {
+	if b, ok := a.(*image); ok {
+		return b.RO
+	}
+	return a
+}
+
+// unwrapFeature returns the underlying feature of a possible image.
+func unwrapFeature(f feature.RO) feature.RO {
+	if g, ok := f.(*featureImage); ok {
+		return g.RO
+	}
+	return f
+}
+
+// queryAgents sets the neighbor buffer of the worker to all agents which pass
+// the input filter against the current agent of the worker. In a wrapped world,
+// agents across the edges of the world are added as images.
+func (c *C) queryAgents(w *worker, filter func(b agent.RO) bool) {
+	w.ns = c.broadphase.Query(w.aabb, filter, w.ns[:0])
+	if c.torus == nil {
+		return
+	}
+
+	// The query is run from an image of the current agent, and any
+	// results are shifted back.
+	a := w.a
+	for _, o := range c.torus.offsets(w.aabb, &w.offsets) {
+		w.self.set(a, o[0], o[1])
+		w.a = &w.self
+		shift(w.aabb, o[0], o[1], w.aabb)
+
+		n := len(w.ns)
+		w.ns = c.broadphase.Query(w.aabb, filter, w.ns)
+		for i := n; i < len(w.ns); i++ {
+			w.ns[i] = w.image(w.ns[i], -o[0], -o[1])
+		}
+
+		shift(w.aabb, -o[0], -o[1], w.aabb)
+		w.a = a
+	}
+}
+
+// queryFeatures sets the feature buffer of the worker to all features which
+// pass the input filter against the current agent of the worker. See
+// queryAgents for more information.
+func (c *C) queryFeatures(w *worker, filter func(f feature.RO) bool) {
+	w.fs = c.store.QueryFeatures(w.aabb, filter, w.fs[:0])
+	if c.torus == nil {
+		return
+	}
+
+	a := w.a
+	for _, o := range c.torus.offsets(w.aabb, &w.offsets) {
+		w.self.set(a, o[0], o[1])
+		w.a = &w.self
+		shift(w.aabb, o[0], o[1], w.aabb)
+
+		n := len(w.fs)
+		w.fs = c.store.QueryFeatures(w.aabb, filter, w.fs)
+		for i := n; i < len(w.fs); i++ {
+			w.fs[i] = w.featureImage(w.fs[i], -o[0], -o[1])
+		}
+
+		shift(w.aabb, -o[0], -o[1], w.aabb)
+		w.a = a
+	}
+}
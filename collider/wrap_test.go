@@ -0,0 +1,120 @@
+package collider
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/downflux/go-database/agent"
+	"github.com/downflux/go-database/database"
+	"github.com/downflux/go-database/feature"
+	"github.com/downflux/go-geometry/2d/hyperrectangle"
+	"github.com/downflux/go-geometry/2d/vector"
+	"github.com/downflux/go-geometry/2d/vector/polar"
+)
+
+func TestWrap(t *testing.T) {
+	type config struct {
+		name string
+		wrap *hyperrectangle.R
+		os   []agent.O
+		fs   []feature.O
+
+		// want is the position of the first agent after the tick.
+		want vector.V
+	}
+
+	world := hyperrectangle.New(vector.V{0, 0}, vector.V{10, 10})
+
+	// moving returns an agent at the input position which is already
+	// moving at the input velocity.
+	moving := func(p vector.V, v vector.V) agent.O {
+		o := idle(p)
+		o.TargetVelocity = v
+		o.Velocity = v
+		o.Heading = polar.V{1, math.Atan2(v.Y(), v.X())}
+		return o
+	}
+
+	configs := []config{
+		{
+			name: "Position",
+			wrap: world,
+			os:   []agent.O{moving(vector.V{9.9, 5}, vector.V{5, 0})},
+			want: vector.V{0.4, 5},
+		},
+		{
+			name: "Position/NoWrap",
+			wrap: nil,
+			os:   []agent.O{moving(vector.V{9.9, 5}, vector.V{5, 0})},
+			want: vector.V{10.4, 5},
+		},
+		{
+			name: "Position/Corner",
+			wrap: world,
+			os:   []agent.O{moving(vector.V{0.1, 0.1}, vector.V{-5, -5})},
+			want: vector.V{9.6, 9.6},
+		},
+		{
+			// The neighbor is in contact with the agent across the
+			// edge of the world.
+			name: "Neighbor",
+			wrap: world,
+			os: []agent.O{
+				moving(vector.V{0.4, 5}, vector.V{-1, 0}),
+				idle(vector.V{9.4, 5}),
+			},
+			want: vector.V{0.4, 5},
+		},
+		{
+			name: "Neighbor/NoWrap",
+			wrap: nil,
+			os: []agent.O{
+				moving(vector.V{0.4, 5}, vector.V{-1, 0}),
+				idle(vector.V{9.4, 5}),
+			},
+			want: vector.V{0.3, 5},
+		},
+		{
+			name: "Feature",
+			wrap: world,
+			os:   []agent.O{moving(vector.V{0.4, 5}, vector.V{-1, 0})},
+			fs:   []feature.O{{AABB: *hyperrectangle.New(vector.V{9.9, 0}, vector.V{10, 10})}},
+			want: vector.V{0.4, 5},
+		},
+		{
+			name: "Feature/NoWrap",
+			wrap: nil,
+			os:   []agent.O{moving(vector.V{0.4, 5}, vector.V{-1, 0})},
+			fs:   []feature.O{{AABB: *hyperrectangle.New(vector.V{9.9, 0}, vector.V{10, 10})}},
+			want: vector.V{0.3, 5},
+		},
+	}
+
+	for _, c := range configs {
+		t.Run(c.name, func(t *testing.T) {
+			db := database.New(database.DefaultO)
+			collider := New(db, O{
+				PoolSize: DefaultO.PoolSize,
+				Wrap:     c.wrap,
+			})
+			defer collider.Close()
+
+			var a agent.RO
+			for _, o := range c.os {
+				if b := db.InsertAgent(o); a == nil {
+					a = b
+				}
+			}
+			for _, o := range c.fs {
+				db.InsertFeature(o)
+			}
+
+			collider.Tick(100 * time.Millisecond)
+
+			if got := db.GetAgentOrDie(a.ID()).Position(); !vector.Within(got, c.want) {
+				t.Errorf("Position() = %v, want = %v", got, c.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,391 @@
+// Package kinematics provides the velocity filters used by the collider to
+// generate the next tick velocity and heading of an agent.
+//
+// The filters operate on small interfaces rather than on full database
+// entities, and may be used independently of the collider, e.g. for predictive
+// AI or client-side prediction. Both go-database agents and features satisfy
+// the interfaces here. The API of this package is stable, and follows the
+// semantic versioning of the module.
+//
+// The filters are order dependent, and are applied by the collider in the
+// following order for each agent a, with neighbors ns and features fs:
+//
+//	v.Copy(a.TargetVelocity())
+//
+//	for _, f := range fs { SetFeatureCollisionVelocity(a, f, v) }
+//	for _, n := range ns { SetCollisionVelocity(a, n, v) }
+//
+//	ClampVelocity(a, v)
+//	ClampAcceleration(a, v, d)
+//	ClampHeading(a, d, v, h)
+//
+//	for _, f := range fs { ClampFeatureCollisionVelocity(a, f, v) }
+//	for _, n := range ns { ClampCollisionVelocity(a, n, v) }
+//
+// Agents with non-circular shapes, e.g. vehicles, replace the circle-based
+// collision filters with contact normals computed by Contact and
+// FeatureContact, which take the heading of the agent into account, along
+// with SetContactVelocity and ClampContactVelocity. The new heading generated
+// by ClampHeading should then be rejected if IsRotationBlocked.
+//
+// Features which move, e.g. doors and lifts, replace the feature collision
+// filters with SetMovingFeatureCollisionVelocity and
+// ClampMovingFeatureCollisionVelocity (or SetMovingContactVelocity and
+// ClampMovingContactVelocity for shaped agents), which filter the velocity of
+// the agent relative to the velocity of the feature. Moving features should be
+// filtered before static features in each pass, so that a moving feature
+// cannot push the agent into a static feature.
+package kinematics
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/downflux/go-geometry/2d/hyperrectangle"
+	"github.com/downflux/go-geometry/2d/vector"
+	"github.com/downflux/go-geometry/2d/vector/polar"
+	"github.com/downflux/go-geometry/epsilon"
+
+	dhr "github.com/downflux/go-database/geometry/hyperrectangle"
+)
+
+// Point is an object with a position, e.g. an agent.
+type Point interface {
+	Position() vector.V
+}
+
+// Circle is a point with a radius, e.g. an agent.
+type Circle interface {
+	Point
+	Radius() float64
+}
+
+// Box is an object with an axis-aligned bounding box, e.g. a feature.
+type Box interface {
+	AABB() hyperrectangle.R
+}
+
+// SpeedLimited is an object with a maximum speed.
+type SpeedLimited interface {
+	MaxVelocity() float64
+}
+
+// Accelerating is an object with a current velocity and a maximum scalar
+// acceleration.
+type Accelerating interface {
+	Velocity() vector.V
+	MaxAcceleration() float64
+}
+
+// Rotating is an object with a current heading and a maximum angular velocity.
+type Rotating interface {
+	Heading() polar.V
+	MaxAngularVelocity() float64
+}
+
+const (
+	// tolerance accounts for some floating point errors at feature corners
+	// when agents need to slide past a corner.
+	tolerance = 1e-5
+)
+
+// IsColliding checks if the two input circles overlap or touch.
+func IsColliding(a Circle, b Circle) bool {
+	p, q := a.Position(), b.Position()
+	dx, dy := p.X()-q.X(), p.Y()-q.Y()
+	r := a.Radius() + b.Radius()
+	return dx*dx+dy*dy <= r*r
+}
+
+// ClampCollisionVelocity generates a velocity vector for two colliding
+// objects.
+//
+// The input velocity vector v is a velocity buffer for the first agent a; if
+// this vector points towards the neighbor b, then the vector as a whole is set
+// to zero -- that is, a is forced to stop for the current tick.
+//
+// This is a much simpler way to deal with the three body problem -- this, the
+// case of when the constant "flip-flop" from SetCollisionVelocity can
+// accidentally flip the velocity vector back into a neighbor.
+func ClampCollisionVelocity(a Point, b Point, v vector.M) {
+	// Find the unit collision vector pointing from a to b.
+	buf := vector.M{0, 0}
+	buf.Copy(b.Position())
+	buf.Sub(a.Position())
+
+	// If the vectors are pointing in the same direction, then force the
+	// object to stop moving.
+	if c := vector.Dot(buf.V(), v.V()); c > tolerance {
+		v.SetX(0)
+		v.SetY(0)
+	}
+}
+
+// ClampFeatureCollisionVelocity forces the input velocity vector v of the point
+// a to zero if v points into the input box f.
+//
+// If the point a lies inside the box, v is filtered against the nearest edge of
+// the box.
+func ClampFeatureCollisionVelocity(a Point, f Box, v vector.M) {
+	nx, ny := normal(f.AABB(), a.Position())
+	if c := -nx*v.X() - ny*v.Y(); c > tolerance {
+		v.SetX(0)
+		v.SetY(0)
+	}
+}
+
+// SetCollisionVelocity generates a velocity vector for two colliding objects by
+// setting the normal components to zero. This does not model, and does not
+// intend to model, an inelastic collision -- this is the final check we do to
+// avoid odd rendering behavior where a unit is forced into a collision.
+//
+// In the case of an inelastic collision between three objects, we can imagine a
+// small object stuck between two massive ones. The collision vector for either
+// massive object is not changed very much by the middle object, but because the
+// two massive objects are not yet colliding, they will continue moving towards
+// one another, which will force a collision with the middle object.
+//
+// The collision avoidance layer should generate velocities for each agent which
+// anticipates collisions (which itself may be modeled as a near-field inelastic
+// repulsive force) to avoid unintuitive pathing behavior.
+//
+// This function does not check if we need to generate a collision velocity in
+// the first place -- that should be done by the caller by e.g. checking for
+// radius overlap.
+//
+// To generate a final collision vector between several colliding objects, this
+// function should be called iteratively for a single object and other
+// databases, e.g.
+//
+//	v := vector.M{0, 0}
+//	v.Copy(a.Velocity())
+//
+//	SetCollisionVelocity(a, b, v)
+//	SetCollisionVelocity(a, c, v)
+//
+// This allows us to generate a final velocity for agent a.
+//
+// N.B.: This may generate a velocity vector which flips back into a forbidden
+// zone. In order to take this into account, the caller must do two passes,
+// where the second pass calls ClampCollisionVelocity to force the velocity to
+// zero in case of continued velocity violations.
+func SetCollisionVelocity(a Point, b Point, v vector.M) {
+	// Find the unit collision vector pointing from a to b.
+	buf := vector.M{0, 0}
+	buf.Copy(b.Position())
+	buf.Sub(a.Position())
+	buf.Unit()
+
+	// If the vectors are pointing in the same direction, then force the
+	// object to stop moving.
+	if c := vector.Dot(buf.V(), v.V()); c > tolerance {
+		buf.Scale(c)
+		v.Sub(buf.V())
+	}
+}
+
+// SetFeatureCollisionVelocity removes the component of the input velocity
+// vector v of the point a which points into the input box f, which allows a to
+// slide along the edge of f.
+//
+// If the point a lies inside the box, v is filtered against the nearest edge of
+// the box.
+func SetFeatureCollisionVelocity(a Point, f Box, v vector.M) {
+	nx, ny := normal(f.AABB(), a.Position())
+	if c := -nx*v.X() - ny*v.Y(); c > tolerance {
+		v.SetX(v.X() + c*nx)
+		v.SetY(v.Y() + c*ny)
+	}
+}
+
+// SetMovingFeatureCollisionVelocity removes the component of the input velocity
+// vector v of the circle a which points into the input box f, relative to the
+// velocity u of the box, e.g. a sliding door. This allows a to slide along the
+// edge of f, and pushes a out of the way if f moves into a.
+//
+// Unlike SetFeatureCollisionVelocity, a may close the gap to f over the input
+// duration d, and is pushed out of f if a and f already overlap, e.g. because f
+// moved into a during the previous tick. If the center of a lies inside the
+// box, a is pushed out through the nearest edge of the box.
+func SetMovingFeatureCollisionVelocity(a Circle, f Box, u vector.V, d time.Duration, v vector.M) {
+	nx, ny := normal(f.AABB(), a.Position())
+	SetMovingContactVelocity(-nx, -ny, distance(f.AABB(), a.Position())-a.Radius(), u, d, v)
+}
+
+// ClampMovingFeatureCollisionVelocity forces the input velocity vector v of the
+// circle a to the smallest velocity along the contact normal which keeps a out
+// of the input box f if v points into f relative to the velocity u of f, i.e. a
+// stops at the edge of f, but is still pushed out of the way if f moves into a.
+// See SetMovingFeatureCollisionVelocity for more information.
+func ClampMovingFeatureCollisionVelocity(a Circle, f Box, u vector.V, d time.Duration, v vector.M) {
+	nx, ny := normal(f.AABB(), a.Position())
+	ClampMovingContactVelocity(-nx, -ny, distance(f.AABB(), a.Position())-a.Radius(), u, d, v)
+}
+
+// distance returns the distance between the AABB and the input point p. If p
+// lies inside the AABB, distance returns the negative distance between p and
+// the nearest edge of the AABB.
+func distance(r hyperrectangle.R, p vector.V) float64 {
+	if d := depth(r, p); d > 0 {
+		return -d
+	}
+	x := math.Max(r.Min().X(), math.Min(p.X(), r.Max().X()))
+	y := math.Max(r.Min().Y(), math.Min(p.Y(), r.Max().Y()))
+	return math.Hypot(p.X()-x, p.Y()-y)
+}
+
+// depth returns the distance between the input point p and the nearest edge of
+// the AABB if p lies strictly inside the AABB, and zero otherwise.
+func depth(r hyperrectangle.R, p vector.V) float64 {
+	d := math.Min(
+		math.Min(r.Max().Y()-p.Y(), p.Y()-r.Min().Y()),
+		math.Min(r.Max().X()-p.X(), p.X()-r.Min().X()),
+	)
+	return math.Max(0, d)
+}
+
+// normal returns the unit normal vector of the AABB which points towards the
+// input point p. This is equivalent to the normal returned by
+// dhr.Normal, but does not allocate. If p lies inside the AABB, normal returns
+// the outward normal of the nearest edge, i.e. the direction in which p may
+// leave the AABB the quickest.
+func normal(r hyperrectangle.R, p vector.V) (float64, float64) {
+	vx, vy := p.X(), p.Y()
+	xmin, xmax := r.Min().X(), r.Max().X()
+	ymin, ymax := r.Min().Y(), r.Max().Y()
+
+	var domain dhr.Side
+	if vy-ymax >= 0 {
+		domain |= dhr.SideN
+	}
+	if ymin-vy >= 0 {
+		domain |= dhr.SideS
+	}
+	if vx-xmax >= 0 {
+		domain |= dhr.SideE
+	}
+	if xmin-vx >= 0 {
+		domain |= dhr.SideW
+	}
+
+	var nx, ny, dx, dy float64
+	switch domain {
+	case dhr.SideN:
+		return 0, 1
+	case dhr.SideE:
+		return 1, 0
+	case dhr.SideS:
+		return 0, -1
+	case dhr.SideW:
+		return -1, 0
+	case dhr.CornerNE:
+		nx, ny, dx, dy = vx-xmax, vy-ymax, 1, 1
+	case dhr.CornerSE:
+		nx, ny, dx, dy = vx-xmax, vy-ymin, 1, -1
+	case dhr.CornerSW:
+		nx, ny, dx, dy = vx-xmin, vy-ymin, -1, -1
+	case dhr.CornerNW:
+		nx, ny, dx, dy = vx-xmin, vy-ymax, -1, 1
+	case 0:
+		// Ties are broken in the order N, E, S, W.
+		d := depth(r, p)
+		switch {
+		case ymax-vy == d:
+			return 0, 1
+		case xmax-vx == d:
+			return 1, 0
+		case vy-ymin == d:
+			return 0, -1
+		default:
+			return -1, 0
+		}
+	default:
+		panic(fmt.Sprintf("invalid domain: %v", domain))
+	}
+
+	// The point lies exactly on the corner, so we return the diagonal.
+	if epsilon.Within(math.Sqrt(nx*nx+ny*ny), 0) {
+		nx, ny = dx, dy
+	}
+
+	k := 1 / math.Sqrt(nx*nx+ny*ny)
+	return nx * k, ny * k
+}
+
+// ClampVelocity scales the input velocity vector down to the maximum speed of
+// the input object.
+func ClampVelocity(a SpeedLimited, v vector.M) {
+	if c := vector.Magnitude(v.V()); c > a.MaxVelocity() {
+		v.Scale(a.MaxVelocity() / c)
+	}
+}
+
+// ClampAcceleration checks the scalar magnitude of the change in velocity and
+// ensures the next tick velocity does not exceed the max acceleration.
+//
+// N.B.: This does not use the velocity difference vector -- we are not taking
+// into account the angular acceleration component. This allows us to decompose
+// the maximum acceleration into angular (i.e. ClampHeading) and scalar
+// (i.e. ClampAcceleration) components, which allows for easier agent config
+// generation.
+func ClampAcceleration(a Accelerating, v vector.M, d time.Duration) {
+	t := float64(d) / float64(time.Second)
+
+	mv := vector.Magnitude(a.Velocity())
+	mtarget := vector.Magnitude(v.V())
+
+	dv := mv - mtarget
+	if math.Abs(dv) > t*a.MaxAcceleration() {
+		dv = math.Copysign(t*a.MaxAcceleration(), dv)
+	}
+
+	// Interpret the new velocity as a braking action.
+	if epsilon.Absolute(1e-5).Within(mtarget, 0) {
+		if !epsilon.Absolute(1e-5).Within(mv, 0) {
+			v.Copy(a.Velocity())
+			v.Scale((mv - dv) / mv)
+		}
+	} else {
+		v.Scale((mv - dv) / mtarget)
+	}
+}
+
+// ClampHeading sets the input velocity and heading vectors to the appropriate
+// simulated values for the next tick.
+//
+// TODO(minkezhang): Handle agents that can reverse.
+func ClampHeading(a Rotating, d time.Duration, v vector.M, h polar.M) {
+	if epsilon.Within(vector.Magnitude(v.V()), 0) {
+		return
+	}
+
+	// Convert v into polar coordinates. This is equivalent to polar.Polar,
+	// but does not allocate.
+	pr := math.Sqrt(v.X()*v.X() + v.Y()*v.Y())
+	ptheta := math.Mod(math.Atan2(v.Y(), v.X()), 2*math.Pi)
+	if ptheta < 0 {
+		ptheta += 2 * math.Pi
+	}
+
+	// We do not need to worry about scaling v by t, as we only care about
+	// the angular difference between v and the heading.
+	omega := a.MaxAngularVelocity() * (float64(d) / float64(time.Second))
+
+	htheta := a.Heading().Theta()
+	// Both htheta and ptheta range from 0 to 2π, so we need to find the
+	// optimal rotation direction first.
+	//
+	// See https://math.stackexchange.com/a/2898118.
+	dtheta := math.Mod(ptheta-htheta+3*math.Pi, 2*math.Pi) - math.Pi
+	if math.Abs(dtheta) > omega {
+		dtheta = dtheta / math.Abs(dtheta) * omega
+		h.SetTheta(htheta + dtheta)
+		v.SetX(pr * math.Cos(htheta+dtheta))
+		v.SetY(pr * math.Sin(htheta+dtheta))
+	} else {
+		h.SetTheta(htheta + dtheta)
+	}
+
+	h.Normalize()
+}
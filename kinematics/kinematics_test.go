@@ -17,6 +17,53 @@ import (
 	mfeature "github.com/downflux/go-database/feature/mock"
 )
 
+var (
+	_ Circle       = agent.RO(nil)
+	_ SpeedLimited = agent.RO(nil)
+	_ Accelerating = agent.RO(nil)
+	_ Rotating     = agent.RO(nil)
+	_ Oriented     = agent.RO(nil)
+	_ Box          = feature.RO(nil)
+)
+
+// circle is a minimal Circle which is not backed by a database agent.
+type circle struct {
+	p vector.V
+	r float64
+}
+
+func (c circle) Position() vector.V { return c.p }
+func (c circle) Radius() float64    { return c.r }
+
+func TestIsColliding(t *testing.T) {
+	type config struct {
+		name string
+		a    Circle
+		b    Circle
+		want bool
+	}
+
+	configs := []config{
+		{name: "Overlap", a: circle{p: vector.V{0, 0}, r: 1}, b: circle{p: vector.V{1, 1}, r: 1}, want: true},
+		{name: "Touching", a: circle{p: vector.V{0, 0}, r: 1}, b: circle{p: vector.V{2, 0}, r: 1}, want: true},
+		{name: "Disjoint", a: circle{p: vector.V{0, 0}, r: 1}, b: circle{p: vector.V{2, 2}, r: 1}, want: false},
+		{
+			name: "Agent",
+			a:    magent.New(1, agent.O{Position: vector.V{0, 0}, Radius: 1, Heading: polar.V{1, 0}}),
+			b:    circle{p: vector.V{0, 1.5}, r: 1},
+			want: true,
+		},
+	}
+
+	for _, c := range configs {
+		t.Run(c.name, func(t *testing.T) {
+			if got := IsColliding(c.a, c.b); got != c.want {
+				t.Errorf("IsColliding() = %v, want = %v", got, c.want)
+			}
+		})
+	}
+}
+
 func TestClampFeatureCollisionVelocity(t *testing.T) {
 	type config struct {
 		name string
@@ -57,6 +104,28 @@ func TestClampFeatureCollisionVelocity(t *testing.T) {
 			v:    vector.V{1, -1},
 			want: vector.V{0, 0},
 		},
+		{
+			// A point inside the box is filtered against the
+			// nearest edge, i.e. the left edge here.
+			name: "Inside",
+			p:    vector.V{1.25, 5},
+			aabb: *hyperrectangle.New(
+				vector.V{1, 0},
+				vector.V{2, 10},
+			),
+			v:    vector.V{1, 0},
+			want: vector.V{0, 0},
+		},
+		{
+			name: "Inside/Away",
+			p:    vector.V{1.25, 5},
+			aabb: *hyperrectangle.New(
+				vector.V{1, 0},
+				vector.V{2, 10},
+			),
+			v:    vector.V{-1, 0},
+			want: vector.V{-1, 0},
+		},
 	}
 
 	for _, c := range configs {
@@ -376,6 +445,56 @@ func TestSetFeatureCollisionVelocity(t *testing.T) {
 	}
 }
 
+func TestMovingFeatureCollisionVelocity(t *testing.T) {
+	type config struct {
+		name  string
+		p     vector.V
+		u     vector.V
+		v     vector.V
+		set   vector.V
+		clamp vector.V
+	}
+
+	// The feature lies to the right of the agent, i.e. the agent is in
+	// contact with the feature at (1, 1), with contact normal (1, 0).
+	configs := []config{
+		{name: "Still", p: vector.V{1, 1}, u: vector.V{0, 0}, v: vector.V{1, 1}, set: vector.V{0, 1}, clamp: vector.V{0, 0}},
+		{name: "Push", p: vector.V{1, 1}, u: vector.V{-1, 0}, v: vector.V{0, 1}, set: vector.V{-1, 1}, clamp: vector.V{-1, 0}},
+		{name: "Push/Away", p: vector.V{1, 1}, u: vector.V{-1, 0}, v: vector.V{-2, 1}, set: vector.V{-2, 1}, clamp: vector.V{-2, 1}},
+		{name: "Retreat", p: vector.V{1, 1}, u: vector.V{1, 0}, v: vector.V{2, 1}, set: vector.V{1, 1}, clamp: vector.V{0, 0}},
+		{name: "Retreat/Follow", p: vector.V{1, 1}, u: vector.V{1, 0}, v: vector.V{0.5, 1}, set: vector.V{0.5, 1}, clamp: vector.V{0.5, 1}},
+		{name: "Slide", p: vector.V{1, 1}, u: vector.V{0, 1}, v: vector.V{1, 0}, set: vector.V{0, 0}, clamp: vector.V{0, 0}},
+		{name: "Gap", p: vector.V{0.5, 1}, u: vector.V{0, 0}, v: vector.V{1, 1}, set: vector.V{0.5, 1}, clamp: vector.V{0, 0}},
+		{name: "Overlap", p: vector.V{1.5, 1}, u: vector.V{0, 0}, v: vector.V{0, 0}, set: vector.V{-0.5, 0}, clamp: vector.V{-0.5, 0}},
+		// The center of the agent lies inside the feature, and the agent
+		// is pushed out through the nearest edge.
+		{name: "Inside", p: vector.V{2.5, 1}, u: vector.V{0, 0}, v: vector.V{0, 0}, set: vector.V{-1.5, 0}, clamp: vector.V{-1.5, 0}},
+		{name: "Inside/Push", p: vector.V{2.5, 1}, u: vector.V{-1, 0}, v: vector.V{0, 0}, set: vector.V{-2.5, 0}, clamp: vector.V{-2.5, 0}},
+	}
+
+	for _, c := range configs {
+		t.Run(c.name, func(t *testing.T) {
+			v := vector.M{0, 0}
+			f := mfeature.New(0, feature.O{
+				AABB: *hyperrectangle.New(vector.V{2, 0}, vector.V{10, 10}),
+			})
+			a := circle{p: c.p, r: 1}
+
+			v.Copy(c.v)
+			SetMovingFeatureCollisionVelocity(a, f, c.u, time.Second, v)
+			if got := v.V(); !vector.Within(got, c.set) {
+				t.Errorf("SetMovingFeatureCollisionVelocity() = %v, want = %v", got, c.set)
+			}
+
+			v.Copy(c.v)
+			ClampMovingFeatureCollisionVelocity(a, f, c.u, time.Second, v)
+			if got := v.V(); !vector.Within(got, c.clamp) {
+				t.Errorf("ClampMovingFeatureCollisionVelocity() = %v, want = %v", got, c.clamp)
+			}
+		})
+	}
+}
+
 func TestSetCollisionVelocity(t *testing.T) {
 	type config struct {
 		name string
@@ -543,3 +662,58 @@ func TestClampHeading(t *testing.T) {
 		})
 	}
 }
+
+func TestAllocs(t *testing.T) {
+	a := magent.New(1, agent.O{
+		Position:           vector.V{0, 0.9},
+		Velocity:           vector.V{1, 0},
+		TargetVelocity:     vector.V{1, 1},
+		Heading:            polar.V{1, math.Pi},
+		MaxVelocity:        1,
+		MaxAcceleration:    1,
+		MaxAngularVelocity: math.Pi / 4,
+	})
+	b := magent.New(2, agent.O{
+		Position: vector.V{1, 1},
+		Heading:  polar.V{1, 0},
+	})
+	f := mfeature.New(3, feature.O{
+		AABB: *hyperrectangle.New(vector.V{1, 0}, vector.V{2, 10}),
+	})
+
+	v := vector.M{0, 0}
+	h := polar.M{0, 0}
+
+	type config struct {
+		name string
+		f    func()
+	}
+
+	configs := []config{
+		{name: "SetCollisionVelocity", f: func() { SetCollisionVelocity(a, b, v) }},
+		{name: "ClampCollisionVelocity", f: func() { ClampCollisionVelocity(a, b, v) }},
+		{name: "SetFeatureCollisionVelocity", f: func() { SetFeatureCollisionVelocity(a, f, v) }},
+		{name: "ClampFeatureCollisionVelocity", f: func() { ClampFeatureCollisionVelocity(a, f, v) }},
+		{name: "SetMovingFeatureCollisionVelocity", f: func() { SetMovingFeatureCollisionVelocity(a, f, b.Velocity(), time.Second, v) }},
+		{name: "ClampMovingFeatureCollisionVelocity", f: func() { ClampMovingFeatureCollisionVelocity(a, f, b.Velocity(), time.Second, v) }},
+		{name: "ClampVelocity", f: func() { ClampVelocity(a, v) }},
+		{name: "ClampAcceleration", f: func() { ClampAcceleration(a, v, time.Second) }},
+		{name: "ClampHeading", f: func() { ClampHeading(a, time.Second, v, h) }},
+		{name: "IsColliding", f: func() { IsColliding(a, b) }},
+		{name: "Contact", f: func() { Contact(Place(a, Shape{Length: 1, Radius: 0.5}), Place(b, Shape{Width: 1})) }},
+		{name: "FeatureContact", f: func() { FeatureContact(Place(a, Shape{Length: 1, Radius: 0.5}), f) }},
+		{name: "IsRotationBlocked", f: func() { IsRotationBlocked(Place(a, Shape{Length: 1}), 0, Place(b, Shape{Width: 1})) }},
+	}
+
+	for _, c := range configs {
+		t.Run(c.name, func(t *testing.T) {
+			if got := testing.AllocsPerRun(100, func() {
+				v.Copy(vector.V{1, 1})
+				h.Copy(a.Heading())
+				c.f()
+			}); got != 0 {
+				t.Errorf("AllocsPerRun() = %v, want = 0", got)
+			}
+		})
+	}
+}
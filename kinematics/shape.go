@@ -0,0 +1,236 @@
+package kinematics
+
+import (
+	"math"
+	"time"
+
+	"github.com/downflux/go-geometry/2d/vector"
+	"github.com/downflux/go-geometry/2d/vector/polar"
+)
+
+// Oriented is an object with a position and a heading, e.g. an agent.
+type Oriented interface {
+	Point
+	Heading() polar.V
+}
+
+// Shape is the body of an oriented object, i.e. a rectangle with rounded
+// corners which is centered on the position of the object, and whose X-axis
+// points along the heading of the object.
+//
+// A circle has zero length and width, a capsule has zero width, and an
+// oriented box has zero radius.
+type Shape struct {
+	// Length and Width are the half-extents of the rectangle along and
+	// across the heading respectively.
+	Length float64
+	Width  float64
+
+	// Radius is the radius of the rounded corners of the rectangle.
+	Radius float64
+}
+
+// Bound returns the radius of the smallest circle centered on the object which
+// contains the shape at any heading.
+func (s Shape) Bound() float64 { return math.Sqrt(s.Length*s.Length+s.Width*s.Width) + s.Radius }
+
+// Body is a shape which is placed at a position and heading.
+type Body struct {
+	Shape
+
+	X float64
+	Y float64
+
+	// Theta is the heading of the body in radians.
+	Theta float64
+}
+
+// Place returns the body of the input object with the input shape.
+func Place(o Oriented, s Shape) Body {
+	p := o.Position()
+	return Body{
+		Shape: s,
+		X:     p.X(),
+		Y:     p.Y(),
+		Theta: o.Heading().Theta(),
+	}
+}
+
+// PlaceBox returns the body of the input axis-aligned box.
+func PlaceBox(f Box) Body {
+	r := f.AABB()
+	min, max := r.Min(), r.Max()
+	return Body{
+		Shape: Shape{
+			Length: (max.X() - min.X()) / 2,
+			Width:  (max.Y() - min.Y()) / 2,
+		},
+		X: (min.X() + max.X()) / 2,
+		Y: (min.Y() + max.Y()) / 2,
+	}
+}
+
+// axes returns the unit vectors along and across the heading of the body.
+func (b Body) axes() ([2]float64, [2]float64) {
+	sin, cos := math.Sincos(b.Theta)
+	return [2]float64{cos, sin}, [2]float64{-sin, cos}
+}
+
+// corners returns the corners of the rectangle of the body in order, i.e. such
+// that consecutive corners form the edges of the rectangle.
+func (b Body) corners() [4][2]float64 {
+	u, w := b.axes()
+	l, h := b.Length, b.Width
+	return [4][2]float64{
+		{b.X + l*u[0] + h*w[0], b.Y + l*u[1] + h*w[1]},
+		{b.X - l*u[0] + h*w[0], b.Y - l*u[1] + h*w[1]},
+		{b.X - l*u[0] - h*w[0], b.Y - l*u[1] - h*w[1]},
+		{b.X + l*u[0] - h*w[0], b.Y + l*u[1] - h*w[1]},
+	}
+}
+
+// Contact returns the unit contact normal which points from the body a into
+// the body b, along with the signed distance between the two bodies, which is
+// negative if the bodies overlap, and zero if the bodies are touching.
+func Contact(a Body, b Body) (float64, float64, float64) {
+	pa, pb := a.corners(), b.corners()
+	ua, wa := a.axes()
+	ub, wb := b.axes()
+
+	// Check if the rectangles overlap via the separating axis theorem, in
+	// which case the contact normal is the axis of least penetration.
+	overlap := true
+	depth := math.Inf(1)
+	var nx, ny float64
+	for _, n := range [4][2]float64{ua, wa, ub, wb} {
+		amin, amax := project(pa, n)
+		bmin, bmax := project(pb, n)
+		if amax < bmin || bmax < amin {
+			overlap = false
+			break
+		}
+		if o := amax - bmin; o < depth {
+			depth, nx, ny = o, n[0], n[1]
+		}
+		if o := bmax - amin; o < depth {
+			depth, nx, ny = o, -n[0], -n[1]
+		}
+	}
+	if overlap {
+		return nx, ny, -depth - a.Radius - b.Radius
+	}
+
+	// Otherwise the rectangles are disjoint, and the closest points of
+	// the two rectangles lie on a corner of one of the rectangles.
+	d := math.Inf(1)
+	for i := 0; i < 4; i++ {
+		for j := 0; j < 4; j++ {
+			if x, y, e := closest(pa[i], pb[j], pb[(j+1)%4]); e < d {
+				d, nx, ny = e, x-pa[i][0], y-pa[i][1]
+			}
+			if x, y, e := closest(pb[i], pa[j], pa[(j+1)%4]); e < d {
+				d, nx, ny = e, pb[i][0]-x, pb[i][1]-y
+			}
+		}
+	}
+	return nx / d, ny / d, d - a.Radius - b.Radius
+}
+
+// FeatureContact returns the unit contact normal which points from the body a
+// into the box f, along with the signed distance between the body and the box.
+// See Contact for more information.
+func FeatureContact(a Body, f Box) (float64, float64, float64) {
+	return Contact(a, PlaceBox(f))
+}
+
+// IsRotationBlocked checks if rotating the body a in place to the input heading
+// would swing the body deeper into the body b, i.e. if the rotated body
+// overlaps b by more than the current body does.
+func IsRotationBlocked(a Body, theta float64, b Body) bool {
+	r := a
+	r.Theta = theta
+	_, _, d := Contact(r, b)
+	if d >= -tolerance {
+		return false
+	}
+	_, _, e := Contact(a, b)
+	return d < e-tolerance
+}
+
+// SetContactVelocity removes the component of the input velocity vector v which
+// points along the input unit contact normal, e.g. as returned by Contact. See
+// SetCollisionVelocity for more information.
+func SetContactVelocity(nx float64, ny float64, v vector.M) {
+	if c := nx*v.X() + ny*v.Y(); c > tolerance {
+		v.SetX(v.X() - c*nx)
+		v.SetY(v.Y() - c*ny)
+	}
+}
+
+// ClampContactVelocity forces the input velocity vector v to zero if v points
+// along the input unit contact normal. See ClampCollisionVelocity for more
+// information.
+func ClampContactVelocity(nx float64, ny float64, v vector.M) {
+	if c := nx*v.X() + ny*v.Y(); c > tolerance {
+		v.SetX(0)
+		v.SetY(0)
+	}
+}
+
+// SetMovingContactVelocity removes the component of the input velocity vector v
+// which points along the input unit contact normal, relative to the velocity u
+// of the contact, e.g. a moving feature, and beyond the input gap between the
+// two bodies over the duration d. A negative gap, i.e. an overlap, pushes the
+// body out of the contact. See SetMovingFeatureCollisionVelocity for more
+// information.
+func SetMovingContactVelocity(nx float64, ny float64, gap float64, u vector.V, d time.Duration, v vector.M) {
+	if c := nx*(v.X()-u.X()) + ny*(v.Y()-u.Y()) - slack(gap, d); c > tolerance {
+		v.SetX(v.X() - c*nx)
+		v.SetY(v.Y() - c*ny)
+	}
+}
+
+// ClampMovingContactVelocity forces the input velocity vector v to the smallest
+// velocity along the input unit contact normal which keeps the body out of the
+// contact if v points along the normal relative to the velocity u of the
+// contact. See SetMovingContactVelocity for more information.
+func ClampMovingContactVelocity(nx float64, ny float64, gap float64, u vector.V, d time.Duration, v vector.M) {
+	s := slack(gap, d)
+	if c := nx*(v.X()-u.X()) + ny*(v.Y()-u.Y()) - s; c > tolerance {
+		k := math.Min(0, nx*u.X()+ny*u.Y()+s)
+		v.SetX(k * nx)
+		v.SetY(k * ny)
+	}
+}
+
+// slack returns the speed at which a body may close the input gap over the
+// input duration.
+func slack(gap float64, d time.Duration) float64 {
+	if t := d.Seconds(); t > 0 {
+		return gap / t
+	}
+	return 0
+}
+
+// project returns the interval of the projection of the input corners onto the
+// input axis.
+func project(ps [4][2]float64, n [2]float64) (float64, float64) {
+	min, max := math.Inf(1), math.Inf(-1)
+	for _, p := range ps {
+		c := p[0]*n[0] + p[1]*n[1]
+		min, max = math.Min(min, c), math.Max(max, c)
+	}
+	return min, max
+}
+
+// closest returns the point on the segment (a, b) which is closest to the input
+// point p, along with the distance between the two points.
+func closest(p [2]float64, a [2]float64, b [2]float64) (float64, float64, float64) {
+	dx, dy := b[0]-a[0], b[1]-a[1]
+	var t float64
+	if l := dx*dx + dy*dy; l > 0 {
+		t = math.Max(0, math.Min(1, ((p[0]-a[0])*dx+(p[1]-a[1])*dy)/l))
+	}
+	x, y := a[0]+t*dx, a[1]+t*dy
+	return x, y, math.Sqrt((x-p[0])*(x-p[0]) + (y-p[1])*(y-p[1]))
+}
@@ -0,0 +1,278 @@
+package kinematics
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/downflux/go-database/feature"
+	"github.com/downflux/go-geometry/2d/hyperrectangle"
+	"github.com/downflux/go-geometry/2d/vector"
+	"github.com/downflux/go-geometry/epsilon"
+
+	mfeature "github.com/downflux/go-database/feature/mock"
+)
+
+func TestShapeBound(t *testing.T) {
+	type config struct {
+		name string
+		s    Shape
+		want float64
+	}
+
+	configs := []config{
+		{name: "Circle", s: Shape{Radius: 1}, want: 1},
+		{name: "Capsule", s: Shape{Length: 2, Radius: 1}, want: 3},
+		{name: "Box", s: Shape{Length: 3, Width: 4}, want: 5},
+	}
+
+	for _, c := range configs {
+		t.Run(c.name, func(t *testing.T) {
+			if got := c.s.Bound(); !tol.Within(got, c.want) {
+				t.Errorf("Bound() = %v, want = %v", got, c.want)
+			}
+		})
+	}
+}
+
+func TestContact(t *testing.T) {
+	type config struct {
+		name string
+		a    Body
+		b    Body
+		n    vector.V
+		d    float64
+	}
+
+	circle := Shape{Radius: 1}
+	capsule := Shape{Length: 2, Radius: 0.5}
+	box := Shape{Length: 2, Width: 1}
+
+	configs := []config{
+		{
+			name: "Circle/Disjoint",
+			a:    Body{Shape: circle, X: 0, Y: 0},
+			b:    Body{Shape: circle, X: 3, Y: 4},
+			n:    vector.V{0.6, 0.8},
+			d:    3,
+		},
+		{
+			name: "Circle/Overlap",
+			a:    Body{Shape: circle, X: 0, Y: 0},
+			b:    Body{Shape: circle, X: 1, Y: 0},
+			n:    vector.V{1, 0},
+			d:    -1,
+		},
+		// A capsule lying along the X-axis touches a circle at its end
+		// cap, but not a circle beside its end cap.
+		{
+			name: "Capsule/End",
+			a:    Body{Shape: capsule, X: 0, Y: 0},
+			b:    Body{Shape: circle, X: 3.5, Y: 0},
+			n:    vector.V{1, 0},
+			d:    0,
+		},
+		{
+			name: "Capsule/Side",
+			a:    Body{Shape: capsule, X: 0, Y: 0},
+			b:    Body{Shape: circle, X: 1, Y: 2},
+			n:    vector.V{0, 1},
+			d:    0.5,
+		},
+		// The capsule is rotated to lie along the Y-axis.
+		{
+			name: "Capsule/Rotated",
+			a:    Body{Shape: capsule, X: 0, Y: 0, Theta: math.Pi / 2},
+			b:    Body{Shape: circle, X: 0, Y: 4},
+			n:    vector.V{0, 1},
+			d:    0.5,
+		},
+		{
+			name: "Box/Side",
+			a:    Body{Shape: box, X: 0, Y: 0},
+			b:    Body{Shape: box, X: 0, Y: 3},
+			n:    vector.V{0, 1},
+			d:    1,
+		},
+		{
+			name: "Box/Corner",
+			a:    Body{Shape: box, X: 0, Y: 0},
+			b:    Body{Shape: box, X: 7, Y: 6},
+			n:    vector.V{0.6, 0.8},
+			d:    5,
+		},
+		{
+			name: "Box/Overlap",
+			a:    Body{Shape: box, X: 0, Y: 0},
+			b:    Body{Shape: box, X: 3.5, Y: 0.5},
+			n:    vector.V{1, 0},
+			d:    -0.5,
+		},
+		{
+			name: "Box/Rotated",
+			a:    Body{Shape: box, X: 0, Y: 0, Theta: math.Pi / 2},
+			b:    Body{Shape: circle, X: 2, Y: 0},
+			n:    vector.V{1, 0},
+			d:    0,
+		},
+	}
+
+	for _, c := range configs {
+		t.Run(c.name, func(t *testing.T) {
+			nx, ny, d := Contact(c.a, c.b)
+			if !tol.Within(d, c.d) {
+				t.Errorf("Contact() = _, _, %v, want = _, _, %v", d, c.d)
+			}
+			if got := (vector.V{nx, ny}); !within(got, c.n) {
+				t.Errorf("Contact() = %v, _, want = %v, _", got, c.n)
+			}
+
+			// The contact normal from b into a is reversed.
+			mx, my, e := Contact(c.b, c.a)
+			if !tol.Within(e, c.d) {
+				t.Errorf("Contact() = _, _, %v, want = _, _, %v", e, c.d)
+			}
+			if got, want := (vector.V{mx, my}), vector.Scale(-1, c.n); !within(got, want) {
+				t.Errorf("Contact() = %v, _, want = %v, _", got, want)
+			}
+		})
+	}
+}
+
+func TestFeatureContact(t *testing.T) {
+	f := mfeature.New(1, feature.O{
+		AABB: *hyperrectangle.New(vector.V{1, -5}, vector.V{2, 5}),
+	})
+
+	// A capsule lying along the Y-axis fits beside the wall, but the same
+	// capsule lying along the X-axis would overlap the wall.
+	a := Body{Shape: Shape{Length: 2, Radius: 0.5}, X: 0, Y: 0}
+
+	if _, _, d := FeatureContact(a, f); !tol.Within(d, -1.5) {
+		t.Errorf("FeatureContact() = _, _, %v, want = _, _, %v", d, -1.5)
+	}
+
+	a.Theta = math.Pi / 2
+	nx, ny, d := FeatureContact(a, f)
+	if !tol.Within(d, 0.5) {
+		t.Errorf("FeatureContact() = _, _, %v, want = _, _, %v", d, 0.5)
+	}
+	if got, want := (vector.V{nx, ny}), (vector.V{1, 0}); !within(got, want) {
+		t.Errorf("FeatureContact() = %v, _, want = %v, _", got, want)
+	}
+}
+
+func TestIsRotationBlocked(t *testing.T) {
+	type config struct {
+		name  string
+		a     Body
+		theta float64
+		want  bool
+	}
+
+	// wall is a wall to the right of the origin.
+	wall := Body{Shape: Shape{Length: 1, Width: 10}, X: 2, Y: 0}
+
+	// capsule lies along the wall.
+	capsule := Body{Shape: Shape{Length: 2, Radius: 0.5}, X: 0, Y: 0, Theta: math.Pi / 2}
+
+	configs := []config{
+		{name: "Blocked", a: capsule, theta: 0, want: true},
+		{name: "Parallel", a: capsule, theta: -math.Pi / 2, want: false},
+		{name: "Small", a: capsule, theta: math.Pi/2 - 0.1, want: false},
+		// Circles may always rotate in place.
+		{
+			name:  "Circle",
+			a:     Body{Shape: Shape{Radius: 1.5}, X: 0, Y: 0},
+			theta: math.Pi,
+			want:  false,
+		},
+	}
+
+	for _, c := range configs {
+		t.Run(c.name, func(t *testing.T) {
+			if got := IsRotationBlocked(c.a, c.theta, wall); got != c.want {
+				t.Errorf("IsRotationBlocked() = %v, want = %v", got, c.want)
+			}
+		})
+	}
+}
+
+func TestSetContactVelocity(t *testing.T) {
+	type config struct {
+		name  string
+		n     vector.V
+		v     vector.V
+		set   vector.V
+		clamp vector.V
+	}
+
+	configs := []config{
+		{name: "Into", n: vector.V{1, 0}, v: vector.V{1, 1}, set: vector.V{0, 1}, clamp: vector.V{0, 0}},
+		{name: "Away", n: vector.V{1, 0}, v: vector.V{-1, 1}, set: vector.V{-1, 1}, clamp: vector.V{-1, 1}},
+		{name: "Parallel", n: vector.V{0, 1}, v: vector.V{1, 0}, set: vector.V{1, 0}, clamp: vector.V{1, 0}},
+	}
+
+	for _, c := range configs {
+		t.Run(c.name, func(t *testing.T) {
+			v := vector.M{0, 0}
+
+			v.Copy(c.v)
+			SetContactVelocity(c.n.X(), c.n.Y(), v)
+			if got := v.V(); !within(got, c.set) {
+				t.Errorf("SetContactVelocity() = %v, want = %v", got, c.set)
+			}
+
+			v.Copy(c.v)
+			ClampContactVelocity(c.n.X(), c.n.Y(), v)
+			if got := v.V(); !within(got, c.clamp) {
+				t.Errorf("ClampContactVelocity() = %v, want = %v", got, c.clamp)
+			}
+		})
+	}
+}
+
+func TestMovingContactVelocity(t *testing.T) {
+	type config struct {
+		name  string
+		n     vector.V
+		gap   float64
+		u     vector.V
+		v     vector.V
+		set   vector.V
+		clamp vector.V
+	}
+
+	configs := []config{
+		{name: "Still", n: vector.V{1, 0}, u: vector.V{0, 0}, v: vector.V{1, 1}, set: vector.V{0, 1}, clamp: vector.V{0, 0}},
+		{name: "Push", n: vector.V{1, 0}, u: vector.V{-1, 0}, v: vector.V{0, 1}, set: vector.V{-1, 1}, clamp: vector.V{-1, 0}},
+		{name: "Retreat", n: vector.V{1, 0}, u: vector.V{1, 0}, v: vector.V{2, 1}, set: vector.V{1, 1}, clamp: vector.V{0, 0}},
+		{name: "Gap", n: vector.V{1, 0}, gap: 0.5, u: vector.V{-1, 0}, v: vector.V{0, 1}, set: vector.V{-0.5, 1}, clamp: vector.V{-0.5, 0}},
+		{name: "Gap/Slow", n: vector.V{1, 0}, gap: 0.5, u: vector.V{-0.25, 0}, v: vector.V{0, 1}, set: vector.V{0, 1}, clamp: vector.V{0, 1}},
+		{name: "Overlap", n: vector.V{1, 0}, gap: -0.5, u: vector.V{-1, 0}, v: vector.V{0, 1}, set: vector.V{-1.5, 1}, clamp: vector.V{-1.5, 0}},
+	}
+
+	for _, c := range configs {
+		t.Run(c.name, func(t *testing.T) {
+			v := vector.M{0, 0}
+
+			v.Copy(c.v)
+			SetMovingContactVelocity(c.n.X(), c.n.Y(), c.gap, c.u, time.Second, v)
+			if got := v.V(); !within(got, c.set) {
+				t.Errorf("SetMovingContactVelocity() = %v, want = %v", got, c.set)
+			}
+
+			v.Copy(c.v)
+			ClampMovingContactVelocity(c.n.X(), c.n.Y(), c.gap, c.u, time.Second, v)
+			if got := v.V(); !within(got, c.clamp) {
+				t.Errorf("ClampMovingContactVelocity() = %v, want = %v", got, c.clamp)
+			}
+		})
+	}
+}
+
+// tol is an absolute tolerance, as contact normals may have components which
+// are very close to, but not exactly, zero.
+var tol = epsilon.Absolute(1e-10)
+
+func within(u vector.V, v vector.V) bool { return tol.Within(u.X(), v.X()) && tol.Within(u.Y(), v.Y()) }
@@ -0,0 +1,325 @@
+// Package lod schedules collider ticks by level of detail, where agents far
+// from any focus point (e.g. a player unit or camera) are ticked less often
+// and with a coarser step than agents near the focus points.
+//
+// Agents are assigned to tiers by their distance to the nearest focus point.
+// An agent in a tier with period P is ticked once every P frames, and is
+// advanced by the total duration of the frames since it was last ticked. All
+// due agents across all tiers are ticked together in a single collider tick,
+// and agents which are not due act as stationary obstacles.
+//
+// A coarse step may carry an agent into an agent of a finer tier which was
+// moving in the meantime. In order to avoid overlaps at tier boundaries, an
+// agent is promoted into a finer tier whenever an agent of the finer tier is
+// within reach of its next step. Promotion cascades, i.e. a promoted agent may
+// in turn promote its coarser neighbors.
+//
+// Similarly, a coarse step may carry an agent through a thin feature, as the
+// collider only checks the features around the start of the step. An agent is
+// therefore also promoted into the coarsest tier in which no feature is within
+// reach of its next step, or into the finest tier otherwise.
+package lod
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/downflux/go-bvh/id"
+	"github.com/downflux/go-collider/collider"
+	"github.com/downflux/go-database/agent"
+	"github.com/downflux/go-database/database"
+	"github.com/downflux/go-database/feature"
+	"github.com/downflux/go-geometry/2d/hyperrectangle"
+	"github.com/downflux/go-geometry/2d/vector"
+)
+
+var (
+	// DefaultO provides a default set of tiers, where agents within 50
+	// units of a focus point are ticked every frame, agents within 200
+	// units are ticked every fourth frame, and all other agents are ticked
+	// every sixteenth frame.
+	DefaultO O = O{
+		Tiers: []Tier{
+			{Distance: 50, Period: 1},
+			{Distance: 200, Period: 4},
+			{Period: 16},
+		},
+	}
+)
+
+type Tier struct {
+	// Distance is the maximum distance between an agent in the tier and
+	// its nearest focus point. The distance of the last tier is ignored,
+	// and all remaining agents are assigned to the last tier.
+	Distance float64
+
+	// Period is the number of frames between consecutive ticks of the
+	// agents in the tier.
+	Period int
+}
+
+type O struct {
+	// Tiers is the list of tiers, ordered from the finest to the coarsest
+	// tier. Tier distances must be strictly increasing, and tier periods
+	// must be non-decreasing.
+	Tiers []Tier
+}
+
+// S is a level-of-detail scheduler which drives a collider.
+type S struct {
+	db *database.DB
+	c  *collider.C
+
+	tiers []Tier
+	focus []vector.V
+
+	// frame is the zero-indexed frame of the next tick.
+	frame int
+
+	states map[id.ID]*state
+
+	// schedule is the bound collider schedule, and is stored in order to
+	// avoid allocating a new method value every frame.
+	schedule collider.Schedule
+
+	agents []agent.RO
+	queue  []agent.RO
+}
+
+type state struct {
+	tier int
+
+	// debt is the total duration of the frames since the agent was last
+	// ticked, excluding the current frame.
+	debt time.Duration
+
+	// due indicates the agent is ticked in the current frame, and d is
+	// the duration by which the agent is advanced.
+	due bool
+	d   time.Duration
+}
+
+// New constructs a scheduler for the input database and collider. Agents may
+// be inserted into and deleted from the database between frames, but the
+// collider must only be ticked through the scheduler.
+func New(db *database.DB, c *collider.C, o O) *S {
+	if len(o.Tiers) == 0 {
+		panic("no tiers were specified")
+	}
+	for i, t := range o.Tiers {
+		if t.Period < 1 {
+			panic(fmt.Sprintf("period specified %v for tier %v is smaller than the minimum value of 1", t.Period, i))
+		}
+		if i > 0 && t.Period < o.Tiers[i-1].Period {
+			panic(fmt.Sprintf("period specified %v for tier %v is smaller than the period of the previous tier", t.Period, i))
+		}
+		if i > 0 && i < len(o.Tiers)-1 && t.Distance <= o.Tiers[i-1].Distance {
+			panic(fmt.Sprintf("distance specified %v for tier %v is not larger than the distance of the previous tier", t.Distance, i))
+		}
+	}
+
+	s := &S{
+		db:     db,
+		c:      c,
+		tiers:  append([]Tier(nil), o.Tiers...),
+		states: make(map[id.ID]*state, 1024),
+	}
+	s.schedule = s.lookup
+	return s
+}
+
+// SetFocus sets the focus points of the simulation, e.g. the positions of
+// player units and cameras. If no focus points are set, all agents are
+// assigned to the coarsest tier.
+func (s *S) SetFocus(ps []vector.V) {
+	s.focus = s.focus[:0]
+	for _, p := range ps {
+		s.focus = append(s.focus, vector.V{p.X(), p.Y()})
+	}
+}
+
+// Tier returns the tier of the input agent as of the most recent frame. Tier
+// returns false if the agent has not been scheduled yet.
+func (s *S) Tier(x id.ID) (int, bool) {
+	st, ok := s.states[x]
+	if !ok {
+		return 0, false
+	}
+	return st.tier, true
+}
+
+// Tick advances the simulation by a single frame of the input duration.
+func (s *S) Tick(d time.Duration) { s.TickContext(context.Background(), d) }
+
+// TickContext advances the simulation by a single frame of the input
+// duration. Agents which are due in the frame are advanced by the accumulated
+// duration since they were last ticked, and projectiles are advanced by d.
+//
+// As with collider.C.TickContext, the frame is either applied in full or not
+// at all. A frame which was not applied does not count towards the period of
+// any tier.
+func (s *S) TickContext(ctx context.Context, d time.Duration) error {
+	s.agents = s.agents[:0]
+	for a := range s.db.ListAgents() {
+		s.agents = append(s.agents, a)
+	}
+
+	// Remove agents which have been deleted from the database.
+	if len(s.states) > len(s.agents) {
+		live := make(map[id.ID]bool, len(s.agents))
+		for _, a := range s.agents {
+			live[a.ID()] = true
+		}
+		for x := range s.states {
+			if !live[x] {
+				delete(s.states, x)
+			}
+		}
+	}
+
+	s.assign()
+	s.promote(d)
+
+	for _, a := range s.agents {
+		st := s.states[a.ID()]
+		st.due = s.frame%s.tiers[st.tier].Period == 0
+		st.d = st.debt + d
+	}
+
+	if err := s.c.TickSchedule(ctx, d, s.schedule); err != nil {
+		return err
+	}
+
+	for _, a := range s.agents {
+		st := s.states[a.ID()]
+		if st.due {
+			st.debt = 0
+		} else {
+			st.debt += d
+		}
+	}
+	s.frame++
+	return nil
+}
+
+func (s *S) lookup(a agent.RO) (time.Duration, bool) {
+	st := s.states[a.ID()]
+	return st.d, st.due
+}
+
+// assign sets the tier of each agent by the distance to the nearest focus
+// point.
+func (s *S) assign() {
+	for _, a := range s.agents {
+		st, ok := s.states[a.ID()]
+		if !ok {
+			st = &state{}
+			s.states[a.ID()] = st
+		}
+
+		p := a.Position()
+		r := math.Inf(1)
+		for _, f := range s.focus {
+			dx, dy := p.X()-f.X(), p.Y()-f.Y()
+			r = math.Min(r, dx*dx+dy*dy)
+		}
+
+		st.tier = len(s.tiers) - 1
+		for i, t := range s.tiers[:len(s.tiers)-1] {
+			if r <= t.Distance*t.Distance {
+				st.tier = i
+				break
+			}
+		}
+	}
+}
+
+// reach returns the maximum distance the input agent may travel in its next
+// step if the agent were in the input tier.
+func (s *S) reach(a agent.RO, tier int, d time.Duration) float64 {
+	t := s.states[a.ID()].debt + time.Duration(s.tiers[tier].Period)*d
+	return a.MaxVelocity() * float64(t) / float64(time.Second)
+}
+
+// promote moves agents into finer tiers until no agent in a finer tier is
+// within reach of the next step of an agent in a coarser tier, and no feature
+// is within reach of the next step of an agent in any tier but the finest.
+//
+// An agent a in tier k is promoted into a finer tier j if an agent b in tier j
+// is within the reach of a, where the reach accounts for the next step of a
+// (up to the accumulated debt plus the period of tier k), and the movement of
+// b until a is next ticked in tier j (up to twice the period of tier j).
+func (s *S) promote(d time.Duration) {
+	n := len(s.tiers) - 1
+	if n == 0 {
+		return
+	}
+
+	for _, a := range s.agents {
+		s.clear(a, d)
+	}
+
+	var rmax, vmax float64
+	s.queue = s.queue[:0]
+	for _, a := range s.agents {
+		rmax = math.Max(rmax, a.Radius())
+		vmax = math.Max(vmax, s.reach(a, n, d))
+		if s.states[a.ID()].tier < n {
+			s.queue = append(s.queue, a)
+		}
+	}
+
+	all := func(agent.RO) bool { return true }
+	for len(s.queue) > 0 {
+		b := s.queue[len(s.queue)-1]
+		s.queue = s.queue[:len(s.queue)-1]
+
+		j := s.states[b.ID()].tier
+		rb := b.Radius() + 2*s.reach(b, j, d)
+
+		p := b.Position()
+		k := rb + rmax + vmax
+		q := *hyperrectangle.New(
+			vector.V{p.X() - k, p.Y() - k},
+			vector.V{p.X() + k, p.Y() + k},
+		)
+		for _, a := range s.db.QueryAgents(q, all) {
+			st := s.states[a.ID()]
+			if st == nil || st.tier <= j {
+				continue
+			}
+			r := rb + a.Radius() + s.reach(a, st.tier, d)
+			if vector.SquaredMagnitude(vector.Sub(a.Position(), p)) <= r*r {
+				st.tier = j
+				s.queue = append(s.queue, a)
+			}
+		}
+	}
+}
+
+// clear promotes the input agent into the coarsest tier no coarser than its
+// current tier in which no feature is within reach of its next step, i.e.
+// intersects the AABB swept by the agent moving in any direction. The agent is
+// promoted into the finest tier if every tier is blocked.
+func (s *S) clear(a agent.RO, d time.Duration) {
+	st := s.states[a.ID()]
+	if st.tier == 0 {
+		return
+	}
+
+	all := func(feature.RO) bool { return true }
+
+	p := a.Position()
+	for ; st.tier > 0; st.tier-- {
+		k := a.Radius() + s.reach(a, st.tier, d)
+		q := *hyperrectangle.New(
+			vector.V{p.X() - k, p.Y() - k},
+			vector.V{p.X() + k, p.Y() + k},
+		)
+		if len(s.db.QueryFeatures(q, all)) == 0 {
+			return
+		}
+	}
+}
@@ -0,0 +1,211 @@
+package lod
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/downflux/go-bvh/id"
+	"github.com/downflux/go-collider/collider"
+	"github.com/downflux/go-database/agent"
+	"github.com/downflux/go-database/database"
+	"github.com/downflux/go-database/feature"
+	"github.com/downflux/go-database/flags/size"
+	"github.com/downflux/go-geometry/2d/hyperrectangle"
+	"github.com/downflux/go-geometry/2d/vector"
+	"github.com/downflux/go-geometry/2d/vector/polar"
+	"github.com/downflux/go-geometry/epsilon"
+)
+
+const (
+	R = 0.5
+	D = 20 * time.Millisecond
+)
+
+func body(p vector.V, v vector.V) agent.O {
+	return agent.O{
+		Position:           p,
+		TargetPosition:     p,
+		TargetVelocity:     v,
+		Velocity:           v,
+		MaxVelocity:        math.Max(vector.Magnitude(v), 1e-3),
+		MaxAcceleration:    100,
+		MaxAngularVelocity: math.Pi,
+		Heading:            polar.V{1, math.Atan2(v.Y(), v.X())},
+		Radius:             R,
+		Mass:               1,
+		Size:               size.FSmall,
+	}
+}
+
+func TestAssign(t *testing.T) {
+	type config struct {
+		name  string
+		focus []vector.V
+		p     vector.V
+		want  int
+	}
+
+	configs := []config{
+		{name: "NoFocus", focus: nil, p: vector.V{0, 0}, want: 2},
+		{name: "Near", focus: []vector.V{{0, 0}}, p: vector.V{10, 0}, want: 0},
+		{name: "Boundary", focus: []vector.V{{0, 0}}, p: vector.V{50, 0}, want: 0},
+		{name: "Middle", focus: []vector.V{{0, 0}}, p: vector.V{100, 0}, want: 1},
+		{name: "Far", focus: []vector.V{{0, 0}}, p: vector.V{1000, 0}, want: 2},
+		{name: "NearestFocus", focus: []vector.V{{0, 0}, {1000, 10}}, p: vector.V{1000, 0}, want: 0},
+	}
+
+	for _, c := range configs {
+		t.Run(c.name, func(t *testing.T) {
+			db := database.New(database.DefaultO)
+			col := collider.New(db, collider.DefaultO)
+			defer col.Close()
+
+			x := db.InsertAgent(body(c.p, vector.V{0, 0})).ID()
+
+			s := New(db, col, DefaultO)
+			s.SetFocus(c.focus)
+			s.Tick(D)
+
+			if got, ok := s.Tier(x); !ok || got != c.want {
+				t.Errorf("Tier() = %v, %v, want = %v, true", got, ok, c.want)
+			}
+		})
+	}
+}
+
+// TestSchedule verifies a coarse agent is only moved on its scheduled frames,
+// and is advanced by the accumulated duration of the skipped frames.
+func TestSchedule(t *testing.T) {
+	db := database.New(database.DefaultO)
+	col := collider.New(db, collider.DefaultO)
+	defer col.Close()
+
+	v := vector.V{1, 0}
+	x := db.InsertAgent(body(vector.V{0, 0}, v)).ID()
+
+	s := New(db, col, O{
+		Tiers: []Tier{
+			{Distance: 1, Period: 1},
+			{Period: 4},
+		},
+	})
+	s.SetFocus([]vector.V{{1000, 1000}})
+
+	for i := 0; i < 9; i++ {
+		s.Tick(D)
+
+		// The agent is ticked on frames 0, 4, and 8.
+		n := 4*(i/4) + 1
+		want := vector.Scale(float64(n)*float64(D)/float64(time.Second), v)
+		if got := db.GetAgentOrDie(x).Position(); !vector.Within(got, want) {
+			t.Errorf("Position() = %v, want = %v (frame %v)", got, want, i)
+		}
+	}
+}
+
+// TestPromote verifies a coarse agent moving towards an agent in a finer tier
+// is promoted before it can overlap the finer agent.
+func TestPromote(t *testing.T) {
+	db := database.New(database.DefaultO)
+	col := collider.New(db, collider.DefaultO)
+	defer col.Close()
+
+	fine := db.InsertAgent(body(vector.V{0, 0}, vector.V{0, 0})).ID()
+	coarse := db.InsertAgent(body(vector.V{20, 0}, vector.V{-10, 0})).ID()
+
+	s := New(db, col, O{
+		Tiers: []Tier{
+			{Distance: 0.5, Period: 1},
+			{Period: 8},
+		},
+	})
+	s.SetFocus([]vector.V{{0, 0}})
+
+	// A single frame step of the coarse agent.
+	step := 10 * float64(D) / float64(time.Second)
+
+	var promoted bool
+	for i := 0; i < 200; i++ {
+		s.Tick(D)
+
+		if tier, _ := s.Tier(coarse); tier == 0 {
+			promoted = true
+		}
+
+		a, b := db.GetAgentOrDie(fine), db.GetAgentOrDie(coarse)
+		if got := vector.Magnitude(vector.Sub(a.Position(), b.Position())); got < 2*R-step {
+			t.Fatalf("Magnitude() = %v, want >= %v (frame %v)", got, 2*R-step, i)
+		}
+	}
+
+	if !promoted {
+		t.Errorf("Tier() = 1, want = 0")
+	}
+	if got := db.GetAgentOrDie(coarse).Position().X(); got > 2*R+step {
+		t.Errorf("X() = %v, want <= %v", got, 2*R+step)
+	}
+}
+
+func TestTickDeleted(t *testing.T) {
+	db := database.New(database.DefaultO)
+	col := collider.New(db, collider.DefaultO)
+	defer col.Close()
+
+	s := New(db, col, DefaultO)
+
+	var xs []id.ID
+	for i := 0; i < 10; i++ {
+		xs = append(xs, db.InsertAgent(body(vector.V{float64(2 * i), 0}, vector.V{0, 0})).ID())
+	}
+	s.Tick(D)
+
+	for _, x := range xs[:5] {
+		db.DeleteAgent(x)
+	}
+	s.Tick(D)
+
+	if got := len(s.states); got != 5 {
+		t.Errorf("len() = %v, want = %v", got, 5)
+	}
+	if _, ok := s.Tier(xs[0]); ok {
+		t.Errorf("Tier() = _, %v, want = _, false", ok)
+	}
+}
+
+// TestPromoteFeature verifies a coarse agent moving towards a thin feature is
+// promoted before its coarse step can carry it through the feature.
+func TestPromoteFeature(t *testing.T) {
+	db := database.New(database.DefaultO)
+	col := collider.New(db, collider.DefaultO)
+	defer col.Close()
+
+	x := db.InsertAgent(body(vector.V{0, 0}, vector.V{10, 0})).ID()
+	db.InsertFeature(feature.O{
+		AABB: *hyperrectangle.New(vector.V{5, -10}, vector.V{5.1, 10}),
+	})
+
+	s := New(db, col, O{
+		Tiers: []Tier{
+			{Distance: 1, Period: 1},
+			{Period: 16},
+		},
+	})
+	s.SetFocus([]vector.V{{1000, 1000}})
+
+	// A single frame step of the agent. The collider does not anticipate
+	// contacts, and the agent may overlap the feature by up to a single
+	// step.
+	step := 10 * float64(D) / float64(time.Second)
+
+	for i := 0; i < 64; i++ {
+		s.Tick(D)
+
+		if got := db.GetAgentOrDie(x).Position().X(); got+R > 5+step && !epsilon.Within(got+R, 5+step) {
+			t.Fatalf("X() = %v, want <= %v (frame %v)", got, 5-R+step, i)
+		}
+	}
+	if got, _ := s.Tier(x); got != 0 {
+		t.Errorf("Tier() = %v, want = %v", got, 0)
+	}
+}
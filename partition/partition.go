@@ -0,0 +1,440 @@
+// Package partition splits a large world into a grid of rectangular regions,
+// each of which is simulated by its own database and collider.
+//
+// Before every tick, agents which lie within the halo distance of a
+// neighboring region are mirrored into that region as ghosts. Ghosts act as
+// stationary obstacles in the neighboring region, and are not advanced by its
+// collider, which ensures agents near a region boundary see the same set of
+// neighbors as they would in a single, unpartitioned database. All regions are
+// then ticked concurrently, after which the ghosts are removed, and agents
+// which have crossed a region boundary migrate into their new region.
+//
+// Agents and features are addressed by global IDs, which are stable across
+// migrations. Projectiles are not managed by the partition.
+package partition
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/downflux/go-bvh/id"
+	"github.com/downflux/go-collider/collider"
+	"github.com/downflux/go-database/agent"
+	"github.com/downflux/go-database/database"
+	"github.com/downflux/go-database/feature"
+	"github.com/downflux/go-geometry/2d/hyperrectangle"
+	"github.com/downflux/go-geometry/2d/vector"
+)
+
+type O struct {
+	// Bounds is the extent of the world, which is divided evenly into
+	// Columns x Rows regions. Agents outside the bounds are assigned to the
+	// nearest edge region.
+	Bounds  hyperrectangle.R
+	Columns int
+	Rows    int
+
+	// Halo is the width of the boundary strip of each region which is
+	// mirrored into neighboring regions, and must be at least the largest
+	// agent diameter in the world. The halo must not be larger than the
+	// region size.
+	Halo float64
+
+	// Database and Collider are the options used to construct the database
+	// and collider of each region. Note that each region owns its own
+	// worker pool, and the OnStats hook, if set, is called concurrently by
+	// each region. As all regions are ticked concurrently, the PoolSize of
+	// the collider is split evenly between the regions, with each region
+	// receiving at least the minimum pool size of 2.
+	//
+	// Each region collider only sees the local copies of the agents and
+	// features in its own database, whose IDs differ from the global IDs,
+	// and change whenever an agent migrates. Ghosts are additional copies
+	// which are reinserted every tick. Collider options which track agents
+	// across ticks or report agents and features back to the caller, i.e.
+	// Sleep, JamTicks, OnJam, OnSquish, OnAltitude, Wrap, OnExit, Despawn,
+	// FeatureMotion, Sensor, and OnTrigger, are therefore not supported,
+	// and New panics if any of them is set. The remaining hooks, e.g.
+	// FilterAgent and Priority, are called with the local copies, and must
+	// not depend on the IDs of the input bodies.
+	Database database.O
+	Collider collider.O
+}
+
+// P is a partitioned collider.
+type P struct {
+	regions []*region
+
+	min     vector.V
+	width   float64
+	height  float64
+	columns int
+	rows    int
+	halo    float64
+
+	// agents maps the global ID of each agent to the region which owns the
+	// agent, and features maps the global ID of each feature to its
+	// mirrors in each region.
+	agents   map[id.ID]*entry
+	features map[id.ID][]entry
+
+	// next is the next global ID, which is shared between agents and
+	// features.
+	next id.ID
+}
+
+type entry struct {
+	r int
+	x id.ID
+}
+
+type region struct {
+	db *database.DB
+	c  *collider.C
+
+	// min and max are the bounds of the region. Edge regions extend to
+	// infinity along the world boundary.
+	min vector.V
+	max vector.V
+
+	// ids maps the local IDs of the agents owned by the region to their
+	// global IDs.
+	ids map[id.ID]id.ID
+
+	// ghosts is the set of local IDs of ghost agents in the current tick.
+	ghosts map[id.ID]bool
+
+	// schedule is the bound collider schedule, and is stored in order to
+	// avoid allocating a new closure every tick.
+	schedule collider.Schedule
+	d        time.Duration
+}
+
+// New constructs the regions of the partition and starts their collider
+// worker pools. The caller should call Close once the partition is no longer
+// needed.
+func New(o O) *P {
+	if o.Columns < 1 || o.Rows < 1 {
+		panic(fmt.Sprintf("partition grid specified %v x %v must have at least one region", o.Columns, o.Rows))
+	}
+	if o.Halo <= 0 {
+		panic(fmt.Sprintf("halo specified %v is not positive", o.Halo))
+	}
+
+	for _, c := range []struct {
+		name string
+		set  bool
+	}{
+		{name: "Sleep", set: o.Collider.Sleep},
+		{name: "JamTicks", set: o.Collider.JamTicks > 0},
+		{name: "OnJam", set: o.Collider.OnJam != nil},
+		{name: "OnSquish", set: o.Collider.OnSquish != nil},
+		{name: "OnAltitude", set: o.Collider.OnAltitude != nil},
+		{name: "Wrap", set: o.Collider.Wrap != nil},
+		{name: "OnExit", set: o.Collider.OnExit != nil},
+		{name: "Despawn", set: o.Collider.Despawn},
+		{name: "FeatureMotion", set: o.Collider.FeatureMotion != nil},
+		{name: "Sensor", set: o.Collider.Sensor != nil},
+		{name: "OnTrigger", set: o.Collider.OnTrigger != nil},
+	} {
+		if c.set {
+			panic(fmt.Sprintf("collider option %v is not supported by a partition", c.name))
+		}
+	}
+
+	min, max := o.Bounds.Min(), o.Bounds.Max()
+	if w, h := (max.X()-min.X())/float64(o.Columns), (max.Y()-min.Y())/float64(o.Rows); o.Halo > w || o.Halo > h {
+		panic(fmt.Sprintf("halo specified %v is larger than the region size %v x %v", o.Halo, w, h))
+	}
+
+	p := &P{
+		min:      vector.V{min.X(), min.Y()},
+		width:    (max.X() - min.X()) / float64(o.Columns),
+		height:   (max.Y() - min.Y()) / float64(o.Rows),
+		columns:  o.Columns,
+		rows:     o.Rows,
+		halo:     o.Halo,
+		agents:   map[id.ID]*entry{},
+		features: map[id.ID][]entry{},
+	}
+
+	co := o.Collider
+	co.PoolSize = int(math.Max(2, float64(o.Collider.PoolSize/(o.Columns*o.Rows))))
+
+	for j := 0; j < o.Rows; j++ {
+		for i := 0; i < o.Columns; i++ {
+			db := database.New(o.Database)
+			r := &region{
+				db:     db,
+				c:      collider.New(db, co),
+				min:    vector.V{p.min.X() + float64(i)*p.width, p.min.Y() + float64(j)*p.height},
+				max:    vector.V{p.min.X() + float64(i+1)*p.width, p.min.Y() + float64(j+1)*p.height},
+				ids:    map[id.ID]id.ID{},
+				ghosts: map[id.ID]bool{},
+			}
+			if i == 0 {
+				r.min.M().SetX(math.Inf(-1))
+			}
+			if j == 0 {
+				r.min.M().SetY(math.Inf(-1))
+			}
+			if i == o.Columns-1 {
+				r.max.M().SetX(math.Inf(1))
+			}
+			if j == o.Rows-1 {
+				r.max.M().SetY(math.Inf(1))
+			}
+			r.schedule = func(a agent.RO) (time.Duration, bool) { return r.d, !r.ghosts[a.ID()] }
+
+			p.regions = append(p.regions, r)
+		}
+	}
+	return p
+}
+
+// Close stops the worker pools of all regions.
+func (p *P) Close() {
+	for _, r := range p.regions {
+		r.c.Close()
+	}
+}
+
+// Region returns the index of the region which contains the input position.
+// Regions are indexed in row-major order.
+func (p *P) Region(v vector.V) int {
+	i := int(math.Floor((v.X() - p.min.X()) / p.width))
+	j := int(math.Floor((v.Y() - p.min.Y()) / p.height))
+	i = int(math.Max(0, math.Min(float64(p.columns-1), float64(i))))
+	j = int(math.Max(0, math.Min(float64(p.rows-1), float64(j))))
+	return j*p.columns + i
+}
+
+// InsertAgent inserts a new agent into the region which contains its position,
+// and returns the global ID of the agent.
+func (p *P) InsertAgent(o agent.O) id.ID {
+	x := p.next
+	p.next++
+
+	k := p.Region(o.Position)
+	r := p.regions[k]
+	y := r.db.InsertAgent(o).ID()
+	r.ids[y] = x
+	p.agents[x] = &entry{r: k, x: y}
+	return x
+}
+
+func (p *P) DeleteAgent(x id.ID) {
+	e := p.entry(x)
+	r := p.regions[e.r]
+	r.db.DeleteAgent(e.x)
+	delete(r.ids, e.x)
+	delete(p.agents, x)
+}
+
+// GetAgentOrDie returns the agent with the input global ID. The ID of the
+// returned agent is the global ID.
+func (p *P) GetAgentOrDie(x id.ID) agent.RO {
+	e := p.entry(x)
+	return &global{RO: p.regions[e.r].db.GetAgentOrDie(e.x), x: x}
+}
+
+// ListAgents returns all agents in the partition. The IDs of the returned
+// agents are the global IDs.
+func (p *P) ListAgents() []agent.RO {
+	as := make([]agent.RO, 0, len(p.agents))
+	for _, r := range p.regions {
+		for a := range r.db.ListAgents() {
+			as = append(as, &global{RO: a, x: r.ids[a.ID()]})
+		}
+	}
+	return as
+}
+
+func (p *P) SetAgentTargetVelocity(x id.ID, v vector.V) {
+	e := p.entry(x)
+	p.regions[e.r].db.SetAgentTargetVelocity(e.x, v)
+}
+
+func (p *P) SetAgentTargetPosition(x id.ID, v vector.V) {
+	e := p.entry(x)
+	p.regions[e.r].db.SetAgentTargetPosition(e.x, v)
+}
+
+// InsertFeature inserts a new feature into all regions which the feature
+// overlaps, including the halo of each region, and returns the global ID of
+// the feature.
+func (p *P) InsertFeature(o feature.O) id.ID {
+	x := p.next
+	p.next++
+
+	min, max := o.AABB.Min(), o.AABB.Max()
+	var es []entry
+	for k, r := range p.regions {
+		if max.X() < r.min.X()-p.halo || min.X() > r.max.X()+p.halo || max.Y() < r.min.Y()-p.halo || min.Y() > r.max.Y()+p.halo {
+			continue
+		}
+		es = append(es, entry{r: k, x: r.db.InsertFeature(o).ID()})
+	}
+	p.features[x] = es
+	return x
+}
+
+func (p *P) DeleteFeature(x id.ID) {
+	es, ok := p.features[x]
+	if !ok {
+		panic(fmt.Sprintf("cannot find feature %v", x))
+	}
+	for _, e := range es {
+		p.regions[e.r].db.DeleteFeature(e.x)
+	}
+	delete(p.features, x)
+}
+
+func (p *P) entry(x id.ID) *entry {
+	e, ok := p.agents[x]
+	if !ok {
+		panic(fmt.Sprintf("cannot find agent %v", x))
+	}
+	return e
+}
+
+// Tick advances the world by one tick. Tick must not be called concurrently.
+func (p *P) Tick(d time.Duration) { p.TickContext(context.Background(), d) }
+
+// TickContext advances the world by one tick, and returns a non-nil error if
+// the tick of any region was not applied, e.g. because the input context was
+// done before the region finished generating its velocities. The error wraps
+// the context error. TickContext must not be called concurrently.
+//
+// N.B.: Each region applies its tick independently, and a cancelled tick may
+// therefore only be applied by a subset of the regions.
+func (p *P) TickContext(ctx context.Context, d time.Duration) error {
+	p.mirror()
+
+	errs := make([]error, len(p.regions))
+
+	var wg sync.WaitGroup
+	wg.Add(len(p.regions))
+	for k, r := range p.regions {
+		k, r := k, r
+		r.d = d
+		go func() {
+			defer wg.Done()
+			errs[k] = r.c.TickSchedule(ctx, d, r.schedule)
+		}()
+	}
+	wg.Wait()
+
+	for _, r := range p.regions {
+		for x := range r.ghosts {
+			r.db.DeleteAgent(x)
+			delete(r.ghosts, x)
+		}
+	}
+
+	p.migrate()
+
+	for k, err := range errs {
+		if err != nil {
+			return fmt.Errorf("tick of region %v was not applied: %w", k, err)
+		}
+	}
+	return nil
+}
+
+// mirror inserts the ghosts of all agents which lie within the halo of a
+// neighboring region.
+func (p *P) mirror() {
+	type ghost struct {
+		r int
+		o agent.O
+	}
+
+	var gs []ghost
+	for k, r := range p.regions {
+		i, j := k%p.columns, k/p.columns
+		for a := range r.db.ListAgents() {
+			q := a.Position()
+			for l := j - 1; l <= j+1; l++ {
+				for m := i - 1; m <= i+1; m++ {
+					if l < 0 || l >= p.rows || m < 0 || m >= p.columns || (l == j && m == i) {
+						continue
+					}
+					s := p.regions[l*p.columns+m]
+					dx := math.Max(0, math.Max(s.min.X()-q.X(), q.X()-s.max.X()))
+					dy := math.Max(0, math.Max(s.min.Y()-q.Y(), q.Y()-s.max.Y()))
+					if dx <= p.halo && dy <= p.halo {
+						gs = append(gs, ghost{r: l*p.columns + m, o: options(a)})
+					}
+				}
+			}
+		}
+	}
+
+	for _, g := range gs {
+		r := p.regions[g.r]
+		r.ghosts[r.db.InsertAgent(g.o).ID()] = true
+	}
+}
+
+// migrate moves each agent which has crossed a region boundary into the region
+// which now contains the agent.
+func (p *P) migrate() {
+	type migrant struct {
+		x id.ID
+		r int
+		a agent.RO
+	}
+
+	var ms []migrant
+	for k, r := range p.regions {
+		for a := range r.db.ListAgents() {
+			if l := p.Region(a.Position()); l != k {
+				ms = append(ms, migrant{x: r.ids[a.ID()], r: l, a: a})
+			}
+		}
+	}
+
+	for _, m := range ms {
+		e := p.agents[m.x]
+		src, dst := p.regions[e.r], p.regions[m.r]
+
+		y := dst.db.InsertAgent(options(m.a)).ID()
+		src.db.DeleteAgent(e.x)
+		delete(src.ids, e.x)
+
+		dst.ids[y] = m.x
+		e.r, e.x = m.r, y
+	}
+}
+
+// options returns the options which reconstruct the input agent.
+func options(a agent.RO) agent.O {
+	return agent.O{
+		Position:           a.Position(),
+		TargetPosition:     a.TargetPosition(),
+		Velocity:           a.Velocity(),
+		TargetVelocity:     a.TargetVelocity(),
+		Heading:            a.Heading(),
+		Radius:             a.Radius(),
+		Mass:               a.Mass(),
+		MaxVelocity:        a.MaxVelocity(),
+		MaxAngularVelocity: a.MaxAngularVelocity(),
+		MaxAcceleration:    a.MaxAcceleration(),
+		Flags:              a.Flags(),
+		Size:               a.Size(),
+		Team:               a.Team(),
+		Move:               a.MoveMode(),
+	}
+}
+
+// global wraps an agent owned by a region, and reports its global ID.
+type global struct {
+	agent.RO
+	x id.ID
+}
+
+func (g *global) ID() id.ID { return g.x }
@@ -0,0 +1,288 @@
+package partition
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/downflux/go-bvh/id"
+	"github.com/downflux/go-collider/collider"
+	"github.com/downflux/go-database/agent"
+	"github.com/downflux/go-database/database"
+	"github.com/downflux/go-database/feature"
+	"github.com/downflux/go-database/flags/size"
+	"github.com/downflux/go-database/projectile"
+	"github.com/downflux/go-geometry/2d/hyperrectangle"
+	"github.com/downflux/go-geometry/2d/vector"
+	"github.com/downflux/go-geometry/2d/vector/polar"
+)
+
+const (
+	R = 0.5
+)
+
+func rn(rng *rand.Rand, min, max float64) float64 { return min + rng.Float64()*(max-min) }
+func rv(rng *rand.Rand, min, max float64) vector.V {
+	return vector.V{rn(rng, min, max), rn(rng, min, max)}
+}
+
+// world generates a dense set of agents which move towards the center of the
+// world, and therefore across region boundaries, along with a set of walls.
+// The world is generated from a fixed seed, so that the same agents migrate
+// between regions in every run.
+func world(n int, max float64) ([]agent.O, []feature.O) {
+	rng := rand.New(rand.NewSource(1))
+
+	var as []agent.O
+	for i := 0; i < n; i++ {
+		p := rv(rng, 0, max)
+		// Agents must not spawn inside the central feature.
+		for math.Abs(p.X()-max/2) < 3 && math.Abs(p.Y()-max/2) < 3 {
+			p = rv(rng, 0, max)
+		}
+		v := vector.Scale(5/max, vector.Sub(vector.V{max / 2, max / 2}, p))
+		as = append(as, agent.O{
+			Radius:             R,
+			Mass:               1,
+			Position:           p,
+			TargetPosition:     vector.V{max / 2, max / 2},
+			TargetVelocity:     v,
+			Velocity:           v,
+			MaxVelocity:        10,
+			MaxAcceleration:    10,
+			MaxAngularVelocity: math.Pi,
+			Heading:            polar.V{1, math.Atan2(v.Y(), v.X())},
+			Size:               size.FSmall,
+		})
+	}
+	fs := []feature.O{
+		{AABB: *hyperrectangle.New(vector.V{-1, -1}, vector.V{0, max + 1})},
+		{AABB: *hyperrectangle.New(vector.V{max, -1}, vector.V{max + 1, max + 1})},
+		{AABB: *hyperrectangle.New(vector.V{max/2 - 2, max/2 - 2}, vector.V{max/2 + 2, max/2 + 2})},
+	}
+	return as, fs
+}
+
+func TestTick(t *testing.T) {
+	type config struct {
+		name    string
+		columns int
+		rows    int
+	}
+
+	configs := []config{
+		{name: "1x1", columns: 1, rows: 1},
+		{name: "2x1", columns: 2, rows: 1},
+		{name: "3x3", columns: 3, rows: 3},
+	}
+
+	const n = 600
+	max := math.Sqrt(float64(n) * math.Pi * R * R / 0.3)
+	as, fs := world(n, max)
+
+	// Simulate the reference world in a single database.
+	db := database.New(database.DefaultO)
+	c := collider.New(db, collider.DefaultO)
+	defer c.Close()
+
+	var xs []id.ID
+	for _, o := range as {
+		xs = append(xs, db.InsertAgent(o).ID())
+	}
+	for _, o := range fs {
+		db.InsertFeature(o)
+	}
+	for i := 0; i < 100; i++ {
+		c.Tick(20 * time.Millisecond)
+	}
+
+	for _, c := range configs {
+		t.Run(c.name, func(t *testing.T) {
+			p := New(O{
+				Bounds:   *hyperrectangle.New(vector.V{0, 0}, vector.V{max, max}),
+				Columns:  c.columns,
+				Rows:     c.rows,
+				Halo:     2 * R,
+				Database: database.DefaultO,
+				Collider: collider.DefaultO,
+			})
+			defer p.Close()
+
+			var ys []id.ID
+			for _, o := range as {
+				ys = append(ys, p.InsertAgent(o))
+			}
+			for _, o := range fs {
+				p.InsertFeature(o)
+			}
+
+			var migrated bool
+			for i := 0; i < 100; i++ {
+				regions := map[id.ID]int{}
+				for _, y := range ys {
+					regions[y] = p.agents[y].r
+				}
+				p.Tick(20 * time.Millisecond)
+				for _, y := range ys {
+					if p.agents[y].r != regions[y] {
+						migrated = true
+					}
+				}
+			}
+			if c.columns*c.rows > 1 && !migrated {
+				t.Errorf("no agents migrated between regions")
+			}
+
+			for i, y := range ys {
+				want, got := db.GetAgentOrDie(xs[i]), p.GetAgentOrDie(y)
+				if got.ID() != y {
+					t.Fatalf("ID() = %v, want = %v", got.ID(), y)
+				}
+				if !vector.Within(got.Position(), want.Position()) {
+					t.Errorf("Position() = %v, want = %v", got.Position(), want.Position())
+				}
+				if !vector.Within(got.Velocity(), want.Velocity()) {
+					t.Errorf("Velocity() = %v, want = %v", got.Velocity(), want.Velocity())
+				}
+			}
+
+			if got := len(p.ListAgents()); got != n {
+				t.Errorf("len() = %v, want = %v", got, n)
+			}
+		})
+	}
+}
+
+func TestTickContext(t *testing.T) {
+	const n = 100
+	max := math.Sqrt(float64(n) * math.Pi * R * R / 0.3)
+	as, fs := world(n, max)
+
+	p := New(O{
+		Bounds:   *hyperrectangle.New(vector.V{0, 0}, vector.V{max, max}),
+		Columns:  2,
+		Rows:     2,
+		Halo:     2 * R,
+		Database: database.DefaultO,
+		Collider: collider.DefaultO,
+	})
+	defer p.Close()
+
+	var ys []id.ID
+	for _, o := range as {
+		ys = append(ys, p.InsertAgent(o))
+	}
+	for _, o := range fs {
+		p.InsertFeature(o)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if err := p.TickContext(ctx, 20*time.Millisecond); !errors.Is(err, context.Canceled) {
+		t.Fatalf("TickContext() = %v, want = %v", err, context.Canceled)
+	}
+	for i, y := range ys {
+		if got, want := p.GetAgentOrDie(y).Position(), as[i].Position; !vector.Within(got, want) {
+			t.Errorf("Position() = %v, want = %v", got, want)
+		}
+	}
+	if got := len(p.ListAgents()); got != n {
+		t.Errorf("len() = %v, want = %v", got, n)
+	}
+}
+
+// TestNewUnsupported verifies collider options which depend on the IDs of the
+// region-local agents and features are rejected.
+func TestNewUnsupported(t *testing.T) {
+	type config struct {
+		name string
+		o    collider.O
+	}
+
+	wrap := hyperrectangle.New(vector.V{0, 0}, vector.V{10, 10})
+	configs := []config{
+		{name: "Sleep", o: collider.O{Sleep: true}},
+		{name: "JamTicks", o: collider.O{JamTicks: 1}},
+		{name: "OnJam", o: collider.O{OnJam: func(as []agent.RO) {}}},
+		{name: "OnSquish", o: collider.O{OnSquish: func(es []collider.Squish) {}}},
+		{name: "OnAltitude", o: collider.O{OnAltitude: func(a agent.RO, k collider.Altitude) {}}},
+		{name: "Wrap", o: collider.O{Wrap: wrap}},
+		{name: "OnExit", o: collider.O{OnExit: func(ps []projectile.RO) {}}},
+		{name: "Despawn", o: collider.O{Despawn: true}},
+		{name: "FeatureMotion", o: collider.O{FeatureMotion: func(f feature.RO) collider.Motion { return collider.Motion{} }}},
+		{name: "Sensor", o: collider.O{Sensor: func(f feature.RO) bool { return true }}},
+		{name: "OnTrigger", o: collider.O{OnTrigger: func(ts []collider.Trigger) {}}},
+	}
+
+	for _, c := range configs {
+		t.Run(c.name, func(t *testing.T) {
+			c.o.PoolSize = collider.DefaultO.PoolSize
+			defer func() {
+				if r := recover(); r == nil {
+					t.Errorf("New() did not panic")
+				}
+			}()
+			New(O{
+				Bounds:   *hyperrectangle.New(vector.V{0, 0}, vector.V{10, 10}),
+				Columns:  2,
+				Rows:     2,
+				Halo:     2 * R,
+				Database: database.DefaultO,
+				Collider: c.o,
+			}).Close()
+		})
+	}
+}
+
+func BenchmarkTick(b *testing.B) {
+	type config struct {
+		name    string
+		n       int
+		regions int
+	}
+
+	configs := []config{}
+	for _, n := range []int{1e4, 1e5} {
+		for _, regions := range []int{1, 2, 4} {
+			configs = append(configs, config{
+				name:    fmt.Sprintf("N=%v/Regions=%vx%v", n, regions, regions),
+				n:       n,
+				regions: regions,
+			})
+		}
+	}
+
+	for _, c := range configs {
+		b.Run(c.name, func(b *testing.B) {
+			max := math.Sqrt(float64(c.n) * math.Pi * R * R / 0.1)
+			as, fs := world(c.n, max)
+
+			p := New(O{
+				Bounds:   *hyperrectangle.New(vector.V{0, 0}, vector.V{max, max}),
+				Columns:  c.regions,
+				Rows:     c.regions,
+				Halo:     2 * R,
+				Database: database.DefaultO,
+				Collider: collider.DefaultO,
+			})
+			defer p.Close()
+
+			for _, o := range as {
+				p.InsertAgent(o)
+			}
+			for _, o := range fs {
+				p.InsertFeature(o)
+			}
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				p.Tick(33 * time.Millisecond)
+			}
+		})
+	}
+}
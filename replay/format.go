@@ -0,0 +1,271 @@
+package replay
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"math"
+	"sort"
+
+	"github.com/downflux/go-bvh/id"
+	"github.com/downflux/go-database/agent"
+	"github.com/downflux/go-database/database"
+	"github.com/downflux/go-database/feature"
+	"github.com/downflux/go-database/flags"
+	"github.com/downflux/go-database/flags/move"
+	"github.com/downflux/go-database/flags/size"
+	"github.com/downflux/go-database/flags/team"
+	"github.com/downflux/go-database/projectile"
+	"github.com/downflux/go-geometry/2d/hyperrectangle"
+	"github.com/downflux/go-geometry/2d/vector"
+	"github.com/downflux/go-geometry/2d/vector/polar"
+)
+
+const (
+	// Version is the current version of the log format. Logs with a
+	// different version cannot be replayed.
+	Version uint16 = 1
+)
+
+var (
+	magic = [4]byte{'D', 'F', 'C', 'R'}
+
+	order = binary.LittleEndian
+)
+
+// tag identifies the record type which immediately follows it in the log.
+type tag uint8
+
+const (
+	tagNone tag = iota
+
+	tagAgent
+	tagFeature
+	tagProjectile
+
+	// tagWorld marks the end of the initial world description.
+	tagWorld
+
+	tagAgentTargetVelocity
+	tagProjectileTargetVelocity
+
+	tagTick
+)
+
+// encoder writes fixed-width little endian values. Errors are sticky, i.e. the
+// first error encountered is preserved and all subsequent writes are no-ops.
+type encoder struct {
+	w   io.Writer
+	buf [8]byte
+	err error
+}
+
+func (e *encoder) write(b []byte) {
+	if e.err != nil {
+		return
+	}
+	_, e.err = e.w.Write(b)
+}
+
+func (e *encoder) u8(x uint8) {
+	e.buf[0] = x
+	e.write(e.buf[:1])
+}
+
+func (e *encoder) u16(x uint16) {
+	order.PutUint16(e.buf[:2], x)
+	e.write(e.buf[:2])
+}
+
+func (e *encoder) u64(x uint64) {
+	order.PutUint64(e.buf[:8], x)
+	e.write(e.buf[:8])
+}
+
+func (e *encoder) f64(x float64) { e.u64(math.Float64bits(x)) }
+
+func (e *encoder) v(v vector.V) {
+	e.f64(v.X())
+	e.f64(v.Y())
+}
+
+func (e *encoder) p(p polar.V) {
+	e.f64(p.R())
+	e.f64(p.Theta())
+}
+
+func (e *encoder) header() {
+	e.write(magic[:])
+	e.u16(Version)
+}
+
+func (e *encoder) agent(a agent.RO) {
+	e.u8(uint8(tagAgent))
+	e.u64(uint64(a.ID()))
+	e.v(a.Position())
+	e.v(a.TargetPosition())
+	e.v(a.Velocity())
+	e.v(a.TargetVelocity())
+	e.p(a.Heading())
+	e.f64(a.Radius())
+	e.f64(a.Mass())
+	e.f64(a.MaxVelocity())
+	e.f64(a.MaxAngularVelocity())
+	e.f64(a.MaxAcceleration())
+	e.u64(uint64(a.Flags()))
+	e.u64(uint64(a.Size()))
+	e.u8(uint8(a.Team()))
+	e.u64(uint64(a.MoveMode()))
+}
+
+func (e *encoder) feature(f feature.RO) {
+	e.u8(uint8(tagFeature))
+	e.u64(uint64(f.ID()))
+	e.v(f.AABB().Min())
+	e.v(f.AABB().Max())
+	e.u64(uint64(f.Flags()))
+	e.u8(uint8(f.Team()))
+}
+
+func (e *encoder) projectile(p projectile.RO) {
+	e.u8(uint8(tagProjectile))
+	e.u64(uint64(p.ID()))
+	e.v(p.Position())
+	e.v(p.TargetPosition())
+	e.v(p.Velocity())
+	e.v(p.TargetVelocity())
+	e.p(p.Heading())
+	e.f64(p.Radius())
+	e.u64(uint64(p.Flags()))
+	e.u8(uint8(p.Team()))
+}
+
+// decoder reads values written by the encoder. As with the encoder, errors
+// are sticky.
+type decoder struct {
+	r   io.Reader
+	buf [8]byte
+	err error
+}
+
+func (d *decoder) read(b []byte) {
+	if d.err != nil {
+		return
+	}
+	_, d.err = io.ReadFull(d.r, b)
+}
+
+func (d *decoder) u8() uint8 {
+	d.read(d.buf[:1])
+	return d.buf[0]
+}
+
+func (d *decoder) u16() uint16 {
+	d.read(d.buf[:2])
+	return order.Uint16(d.buf[:2])
+}
+
+func (d *decoder) u64() uint64 {
+	d.read(d.buf[:8])
+	return order.Uint64(d.buf[:8])
+}
+
+func (d *decoder) f64() float64 { return math.Float64frombits(d.u64()) }
+func (d *decoder) v() vector.V  { return vector.V{d.f64(), d.f64()} }
+func (d *decoder) p() polar.V   { return polar.V{d.f64(), d.f64()} }
+
+func (d *decoder) header() {
+	var m [4]byte
+	d.read(m[:])
+	if d.err != nil {
+		return
+	}
+	if m != magic {
+		d.err = fmt.Errorf("invalid replay log header %q", m[:])
+		return
+	}
+	if v := d.u16(); d.err == nil && v != Version {
+		d.err = fmt.Errorf("unsupported replay log version %v, want = %v", v, Version)
+	}
+}
+
+func (d *decoder) agent() (id.ID, agent.O) {
+	x := id.ID(d.u64())
+	return x, agent.O{
+		Position:           d.v(),
+		TargetPosition:     d.v(),
+		Velocity:           d.v(),
+		TargetVelocity:     d.v(),
+		Heading:            d.p(),
+		Radius:             d.f64(),
+		Mass:               d.f64(),
+		MaxVelocity:        d.f64(),
+		MaxAngularVelocity: d.f64(),
+		MaxAcceleration:    d.f64(),
+		Flags:              flags.F(d.u64()),
+		Size:               size.F(d.u64()),
+		Team:               team.F(d.u8()),
+		Move:               move.F(d.u64()),
+	}
+}
+
+func (d *decoder) feature() (id.ID, feature.O) {
+	x := id.ID(d.u64())
+	min, max := d.v(), d.v()
+	return x, feature.O{
+		AABB:  *hyperrectangle.New(min, max),
+		Flags: flags.F(d.u64()),
+		Team:  team.F(d.u8()),
+	}
+}
+
+func (d *decoder) projectile() (id.ID, projectile.O) {
+	x := id.ID(d.u64())
+	return x, projectile.O{
+		Position:       d.v(),
+		TargetPosition: d.v(),
+		Velocity:       d.v(),
+		TargetVelocity: d.v(),
+		Heading:        d.p(),
+		Radius:         d.f64(),
+		Flags:          flags.F(d.u64()),
+		Team:           team.F(d.u8()),
+	}
+}
+
+// Checksum generates a hash of the mutable simulation state of all agents and
+// projectiles in the database, i.e. the values which are changed by a
+// collider tick.
+//
+// Entities are hashed in ID order. Because the replayer inserts entities into
+// a fresh database in the same relative order as the original, the checksum of
+// a replayed database matches the checksum of the recorded database even
+// though the absolute IDs may differ.
+func Checksum(db *database.DB) uint64 {
+	as := make([]agent.RO, 0, 256)
+	for a := range db.ListAgents() {
+		as = append(as, a)
+	}
+	sort.Slice(as, func(i, j int) bool { return as[i].ID() < as[j].ID() })
+
+	ps := make([]projectile.RO, 0, 256)
+	for p := range db.ListProjectiles() {
+		ps = append(ps, p)
+	}
+	sort.Slice(ps, func(i, j int) bool { return ps[i].ID() < ps[j].ID() })
+
+	h := fnv.New64a()
+	e := &encoder{w: h}
+	for _, a := range as {
+		e.v(a.Position())
+		e.v(a.Velocity())
+		e.p(a.Heading())
+	}
+	for _, p := range ps {
+		e.v(p.Position())
+		e.v(p.Velocity())
+		e.p(p.Heading())
+	}
+	return h.Sum64()
+}
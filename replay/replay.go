@@ -0,0 +1,313 @@
+// Package replay records the inputs to a collider simulation into a compact
+// binary log, and re-drives a fresh collider from that log.
+//
+// A log consists of the initial world state (i.e. all agents, features, and
+// projectiles at the time the recorder was created), followed by a sequence of
+// target velocity changes and ticks, in the order in which they were issued.
+// Ticks may optionally record a checksum of the simulation state after the
+// tick was applied, which allows the replayer to detect the first tick at
+// which the replay diverges from the original run.
+package replay
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/downflux/go-bvh/id"
+	"github.com/downflux/go-collider/collider"
+	"github.com/downflux/go-database/database"
+	"github.com/downflux/go-geometry/2d/vector"
+)
+
+type O struct {
+	// Checksum records a checksum of the simulation state after every
+	// tick. Generating the checksum requires a full scan of the database.
+	Checksum bool
+}
+
+// Recorder wraps a collider and its underlying database, and logs all
+// simulation inputs issued through the recorder.
+//
+// Mutations made directly on the database (e.g. inserting new agents) after
+// the recorder has been created are not recorded, and will cause the replay to
+// diverge.
+type Recorder struct {
+	db *database.DB
+	c  *collider.C
+
+	checksum bool
+
+	w *bufio.Writer
+	e *encoder
+}
+
+// NewRecorder writes the current state of the database into the log and
+// returns a recorder which will log all subsequent inputs.
+func NewRecorder(w io.Writer, db *database.DB, c *collider.C, o O) (*Recorder, error) {
+	bw := bufio.NewWriter(w)
+	r := &Recorder{
+		db:       db,
+		c:        c,
+		checksum: o.Checksum,
+		w:        bw,
+		e:        &encoder{w: bw},
+	}
+
+	r.e.header()
+
+	// Write entities in global ID order so that a replay into a fresh
+	// database will preserve the relative ordering of IDs.
+	type entity struct {
+		x     id.ID
+		write func()
+	}
+	es := make([]entity, 0, 256)
+	for a := range db.ListAgents() {
+		a := a
+		es = append(es, entity{x: a.ID(), write: func() { r.e.agent(a) }})
+	}
+	for f := range db.ListFeatures() {
+		f := f
+		es = append(es, entity{x: f.ID(), write: func() { r.e.feature(f) }})
+	}
+	for p := range db.ListProjectiles() {
+		p := p
+		es = append(es, entity{x: p.ID(), write: func() { r.e.projectile(p) }})
+	}
+	sort.Slice(es, func(i, j int) bool { return es[i].x < es[j].x })
+	for _, e := range es {
+		e.write()
+	}
+	r.e.u8(uint8(tagWorld))
+
+	if r.e.err != nil {
+		return nil, fmt.Errorf("cannot write initial world state: %w", r.e.err)
+	}
+	return r, nil
+}
+
+// SetAgentTargetVelocity sets the target velocity of the agent in the
+// underlying database and records the change.
+func (r *Recorder) SetAgentTargetVelocity(x id.ID, v vector.V) error {
+	r.db.SetAgentTargetVelocity(x, v)
+
+	r.e.u8(uint8(tagAgentTargetVelocity))
+	r.e.u64(uint64(x))
+	r.e.v(v)
+	return r.e.err
+}
+
+// SetProjectileTargetVelocity sets the target velocity of the projectile in
+// the underlying database and records the change.
+func (r *Recorder) SetProjectileTargetVelocity(x id.ID, v vector.V) error {
+	r.db.SetProjectileTargetVelocity(x, v)
+
+	r.e.u8(uint8(tagProjectileTargetVelocity))
+	r.e.u64(uint64(x))
+	r.e.v(v)
+	return r.e.err
+}
+
+// Tick advances the underlying collider by one tick and records the tick
+// duration, along with the state checksum if configured.
+func (r *Recorder) Tick(d time.Duration) error {
+	r.c.Tick(d)
+
+	r.e.u8(uint8(tagTick))
+	r.e.u64(uint64(d))
+	if r.checksum {
+		r.e.u8(1)
+		r.e.u64(Checksum(r.db))
+	} else {
+		r.e.u8(0)
+	}
+	return r.e.err
+}
+
+// Flush writes any buffered records to the underlying writer. Flush must be
+// called after the last tick has been recorded.
+func (r *Recorder) Flush() error {
+	if r.e.err != nil {
+		return r.e.err
+	}
+	return r.w.Flush()
+}
+
+// DivergenceError is returned by the replayer when the state checksum of the
+// replayed simulation does not match the recorded checksum.
+type DivergenceError struct {
+	// Tick is the zero-indexed tick after which the replay diverged.
+	Tick int
+
+	Want uint64
+	Got  uint64
+}
+
+func (e *DivergenceError) Error() string {
+	return fmt.Sprintf("replay diverged at tick %v: checksum = %x, want = %x", e.Tick, e.Got, e.Want)
+}
+
+// Replayer reconstructs the initial world state from a log into a fresh
+// database and collider, and re-drives the simulation one tick at a time.
+type Replayer struct {
+	db *database.DB
+	c  *collider.C
+
+	// ids maps the recorded entity IDs to the IDs generated by the
+	// replay database.
+	ids map[id.ID]id.ID
+
+	d    *decoder
+	tick int
+}
+
+// NewReplayer reads the initial world state from the log and constructs a new
+// database and collider from the state.
+func NewReplayer(r io.Reader, o collider.O) (*Replayer, error) {
+	db := database.New(database.DefaultO)
+	p := &Replayer{
+		db:  db,
+		c:   collider.New(db, o),
+		ids: make(map[id.ID]id.ID, 1024),
+		d:   &decoder{r: bufio.NewReader(r)},
+	}
+
+	p.d.header()
+	for p.d.err == nil {
+		t := tag(p.d.u8())
+		if p.d.err != nil {
+			break
+		}
+		switch t {
+		case tagAgent:
+			x, o := p.d.agent()
+			if p.d.err == nil {
+				p.ids[x] = db.InsertAgent(o).ID()
+			}
+		case tagFeature:
+			x, o := p.d.feature()
+			if p.d.err == nil {
+				p.ids[x] = db.InsertFeature(o).ID()
+			}
+		case tagProjectile:
+			x, o := p.d.projectile()
+			if p.d.err == nil {
+				p.ids[x] = db.InsertProjectile(o).ID()
+			}
+		case tagWorld:
+			return p, nil
+		default:
+			p.Close()
+			return nil, fmt.Errorf("unexpected record type %v in initial world state", t)
+		}
+	}
+	p.Close()
+	if p.d.err == io.EOF {
+		p.d.err = io.ErrUnexpectedEOF
+	}
+	return nil, fmt.Errorf("cannot read initial world state: %w", p.d.err)
+}
+
+// Close releases the resources held by the replay collider.
+func (p *Replayer) Close() { p.c.Close() }
+
+// DB returns the replay database.
+func (p *Replayer) DB() *database.DB { return p.db }
+
+// C returns the replay collider.
+func (p *Replayer) C() *collider.C { return p.c }
+
+// ID returns the replay database ID of an entity given its ID in the recorded
+// database.
+func (p *Replayer) ID(x id.ID) (id.ID, bool) {
+	y, ok := p.ids[x]
+	return y, ok
+}
+
+// Tick returns the number of ticks replayed so far.
+func (p *Replayer) Tick() int { return p.tick }
+
+// Step applies all inputs up to and including the next recorded tick. Step
+// returns io.EOF if there are no more ticks in the log, and a
+// *DivergenceError if the recorded checksum of the tick does not match the
+// replayed state.
+func (p *Replayer) Step() error {
+	for {
+		t := tag(p.d.u8())
+		if p.d.err != nil {
+			return p.d.err
+		}
+		switch t {
+		case tagAgentTargetVelocity:
+			x, v := id.ID(p.d.u64()), p.d.v()
+			if p.d.err != nil {
+				return p.unexpected()
+			}
+			y, ok := p.ids[x]
+			if !ok {
+				return fmt.Errorf("cannot find recorded agent %v", x)
+			}
+			p.db.SetAgentTargetVelocity(y, v)
+		case tagProjectileTargetVelocity:
+			x, v := id.ID(p.d.u64()), p.d.v()
+			if p.d.err != nil {
+				return p.unexpected()
+			}
+			y, ok := p.ids[x]
+			if !ok {
+				return fmt.Errorf("cannot find recorded projectile %v", x)
+			}
+			p.db.SetProjectileTargetVelocity(y, v)
+		case tagTick:
+			d := time.Duration(p.d.u64())
+			ok := p.d.u8() == 1
+			var want uint64
+			if ok {
+				want = p.d.u64()
+			}
+			if p.d.err != nil {
+				return p.unexpected()
+			}
+
+			p.c.Tick(d)
+
+			tick := p.tick
+			p.tick++
+
+			if ok {
+				if got := Checksum(p.db); got != want {
+					return &DivergenceError{
+						Tick: tick,
+						Want: want,
+						Got:  got,
+					}
+				}
+			}
+			return nil
+		default:
+			return fmt.Errorf("unexpected record type %v after tick %v", t, p.tick)
+		}
+	}
+}
+
+// Run replays all remaining ticks in the log. Run returns nil if the log was
+// successfully replayed to the end.
+func (p *Replayer) Run() error {
+	for {
+		if err := p.Step(); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+	}
+}
+
+func (p *Replayer) unexpected() error {
+	if p.d.err == io.EOF {
+		p.d.err = io.ErrUnexpectedEOF
+	}
+	return fmt.Errorf("cannot read record after tick %v: %w", p.tick, p.d.err)
+}
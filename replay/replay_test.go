@@ -0,0 +1,162 @@
+package replay
+
+import (
+	"bytes"
+	"errors"
+	"math"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/downflux/go-bvh/id"
+	"github.com/downflux/go-collider/collider"
+	"github.com/downflux/go-database/agent"
+	"github.com/downflux/go-database/database"
+	"github.com/downflux/go-database/feature"
+	"github.com/downflux/go-database/flags/size"
+	"github.com/downflux/go-database/projectile"
+	"github.com/downflux/go-geometry/2d/hyperrectangle"
+	"github.com/downflux/go-geometry/2d/vector"
+	"github.com/downflux/go-geometry/2d/vector/polar"
+)
+
+func rn(min, max float64) float64  { return min + rand.Float64()*(max-min) }
+func rv(min, max float64) vector.V { return vector.V{rn(min, max), rn(min, max)} }
+
+// record generates a dense simulation and records n ticks of the simulation
+// into a log, along with periodic target velocity changes.
+func record(t *testing.T, n int, o O) ([]byte, *database.DB) {
+	db := database.New(database.DefaultO)
+	c := collider.New(db, collider.DefaultO)
+	defer c.Close()
+
+	xs := make([]id.ID, 0, 100)
+	for i := 0; i < 100; i++ {
+		xs = append(xs, db.InsertAgent(agent.O{
+			Radius:             0.5,
+			Mass:               1,
+			Position:           rv(0, 10),
+			TargetPosition:     vector.V{0, 0},
+			TargetVelocity:     rv(-1, 1),
+			Velocity:           rv(-1, 1),
+			MaxVelocity:        60,
+			MaxAcceleration:    10,
+			MaxAngularVelocity: math.Pi / 4,
+			Heading:            polar.V{1, 0},
+			Size:               size.FSmall,
+		}).ID())
+		// Interleave other entities to ensure IDs are not
+		// contiguous.
+		if i%10 == 0 {
+			db.InsertFeature(feature.O{
+				AABB: *hyperrectangle.New(rv(20, 21), rv(22, 23)),
+			})
+			db.InsertProjectile(projectile.O{
+				Position:       rv(0, 10),
+				TargetPosition: vector.V{0, 0},
+				TargetVelocity: rv(-1, 1),
+				Velocity:       rv(-1, 1),
+				Heading:        polar.V{1, 0},
+				Radius:         1,
+			})
+		}
+	}
+
+	var buf bytes.Buffer
+	r, err := NewRecorder(&buf, db, c, o)
+	if err != nil {
+		t.Fatalf("NewRecorder() = _, %v, want = _, nil", err)
+	}
+	for i := 0; i < n; i++ {
+		if i%5 == 0 {
+			for _, x := range xs[:10] {
+				if err := r.SetAgentTargetVelocity(x, rv(-1, 1)); err != nil {
+					t.Fatalf("SetAgentTargetVelocity() = %v, want = nil", err)
+				}
+			}
+		}
+		if err := r.Tick(20 * time.Millisecond); err != nil {
+			t.Fatalf("Tick() = %v, want = nil", err)
+		}
+	}
+	if err := r.Flush(); err != nil {
+		t.Fatalf("Flush() = %v, want = nil", err)
+	}
+	return buf.Bytes(), db
+}
+
+func TestReplay(t *testing.T) {
+	const n = 50
+
+	for _, checksum := range []bool{false, true} {
+		log, db := record(t, n, O{Checksum: checksum})
+
+		p, err := NewReplayer(bytes.NewReader(log), collider.DefaultO)
+		if err != nil {
+			t.Fatalf("NewReplayer() = _, %v, want = _, nil", err)
+		}
+		defer p.Close()
+
+		if err := p.Run(); err != nil {
+			t.Fatalf("Run() = %v, want = nil", err)
+		}
+		if got := p.Tick(); got != n {
+			t.Errorf("Tick() = %v, want = %v", got, n)
+		}
+		if got, want := Checksum(p.DB()), Checksum(db); got != want {
+			t.Errorf("Checksum() = %x, want = %x", got, want)
+		}
+	}
+}
+
+func TestReplayDivergence(t *testing.T) {
+	log, _ := record(t, 20, O{Checksum: true})
+
+	p, err := NewReplayer(bytes.NewReader(log), collider.DefaultO)
+	if err != nil {
+		t.Fatalf("NewReplayer() = _, %v, want = _, nil", err)
+	}
+	defer p.Close()
+
+	for i := 0; i < 10; i++ {
+		if err := p.Step(); err != nil {
+			t.Fatalf("Step() = %v, want = nil", err)
+		}
+	}
+
+	// Simulate a nondeterministic input which was not recorded.
+	for a := range p.DB().ListAgents() {
+		p.DB().SetAgentTargetVelocity(a.ID(), vector.V{100, 100})
+	}
+
+	var e *DivergenceError
+	if err := p.Run(); !errors.As(err, &e) {
+		t.Fatalf("Run() = %v, want = %T", err, e)
+	}
+	if e.Tick != 10 {
+		t.Errorf("Tick = %v, want = %v", e.Tick, 10)
+	}
+}
+
+func TestNewReplayerInvalid(t *testing.T) {
+	log, _ := record(t, 1, O{})
+
+	type config struct {
+		name string
+		log  []byte
+	}
+
+	configs := []config{
+		{name: "Empty", log: nil},
+		{name: "Header", log: []byte("XXXX\x01\x00")},
+		{name: "Truncated", log: log[:64]},
+	}
+
+	for _, c := range configs {
+		t.Run(c.name, func(t *testing.T) {
+			if _, err := NewReplayer(bytes.NewReader(c.log), collider.DefaultO); err == nil {
+				t.Errorf("NewReplayer() = _, nil, want = _, !nil")
+			}
+		})
+	}
+}